@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+)
+
+// flagSet holds the --flags given to a command. A flag may be repeated, so
+// every value is kept in the order it was typed.
+type flagSet map[string][]string
+
+// parseArgs splits a command's arguments into positional words and flags.
+// Flags are written --name value or --name=value; names listed in boolFlags
+// never take a value.
+func parseArgs(args []string, boolFlags ...string) ([]string, flagSet) {
+	isBool := make(map[string]bool)
+	for _, name := range boolFlags {
+		isBool[name] = true
+	}
+	var words []string
+	flags := make(flagSet)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			words = append(words, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		if !hasValue && !isBool[name] && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			i++
+			value = args[i]
+		}
+		flags[name] = append(flags[name], value)
+	}
+	return words, flags
+}
+
+func (flags flagSet) has(name string) bool {
+	_, exists := flags[name]
+	return exists
+}
+
+// get returns the last value given for name, or "" if it wasn't set.
+func (flags flagSet) get(name string) string {
+	values := flags[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+func (flags flagSet) all(name string) []string {
+	return flags[name]
+}
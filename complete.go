@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Names we've come across while browsing, offered by tab completion.
+var KNOWN_AREAS = make(map[string]struct{})
+var KNOWN_POKEMON = make(map[string]struct{})
+var KNOWN_REGIONS = make(map[string]struct{})
+var KNOWN_LOCATIONS = make(map[string]struct{})
+
+// completionSources says which known names each command takes as its
+// argument.
+var completionSources = map[string]map[string]struct{}{
+	"explore":   KNOWN_AREAS,
+	"locations": KNOWN_REGIONS,
+	"areas":     KNOWN_LOCATIONS,
+	"catch":     KNOWN_POKEMON,
+	"inspect":   KNOWN_POKEMON,
+	"sprite":    KNOWN_POKEMON,
+	"where":     KNOWN_POKEMON,
+	"log":       KNOWN_POKEMON,
+}
+
+// completeWord lists the completions of partial: a command name for the
+// first word, otherwise a name suited to the command being typed.
+func completeWord(words []string, partial string) []string {
+	if len(words) == 0 {
+		var names []string
+		for name := range createRegistry() {
+			names = append(names, name)
+		}
+		return matchPrefix(names, partial)
+	}
+	if len(words) > 1 || strings.HasPrefix(partial, "-") {
+		return nil
+	}
+	var names []string
+	for name := range completionSources[words[0]] {
+		names = append(names, name)
+	}
+	return matchPrefix(names, partial)
+}
+
+func matchPrefix(names []string, prefix string) []string {
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+type knownNames struct {
+	Areas     []string `json:"areas"`
+	Pokemon   []string `json:"pokemon"`
+	Regions   []string `json:"regions,omitempty"`
+	Locations []string `json:"locations,omitempty"`
+	// Localized maps pokemon names in other languages to the API's
+	Localized map[string]string `json:"localized,omitempty"`
+}
+
+// knownCount is how many names completion knows, so callers can tell
+// whether they need saving.
+func knownCount() int {
+	return len(KNOWN_AREAS) + len(KNOWN_POKEMON) + len(KNOWN_REGIONS) + len(KNOWN_LOCATIONS) + len(LOCAL_NAMES)
+}
+
+// loadKnownNames reads the names saved by earlier sessions, so completion
+// works from the first prompt and from the shell.
+func loadKnownNames() {
+	data, err := os.ReadFile(dataPath("names.json"))
+	if err != nil {
+		return
+	}
+	var names knownNames
+	if json.Unmarshal(data, &names) != nil {
+		return
+	}
+	for _, name := range names.Areas {
+		KNOWN_AREAS[name] = struct{}{}
+	}
+	for _, name := range names.Pokemon {
+		KNOWN_POKEMON[name] = struct{}{}
+	}
+	for _, name := range names.Regions {
+		KNOWN_REGIONS[name] = struct{}{}
+	}
+	for _, name := range names.Locations {
+		KNOWN_LOCATIONS[name] = struct{}{}
+	}
+	for local, apiName := range names.Localized {
+		LOCAL_NAMES[local] = apiName
+	}
+}
+
+// saveKnownNames writes the known names to disk. Like the history this is
+// best effort, so errors are ignored.
+func saveKnownNames() {
+	names := knownNames{
+		Areas:     matchPrefix(setNames(KNOWN_AREAS), ""),
+		Pokemon:   matchPrefix(setNames(KNOWN_POKEMON), ""),
+		Regions:   matchPrefix(setNames(KNOWN_REGIONS), ""),
+		Locations: matchPrefix(setNames(KNOWN_LOCATIONS), ""),
+		Localized: LOCAL_NAMES,
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return
+	}
+	path := dataPath("names.json")
+	os.MkdirAll(filepath.Dir(path), 0700)
+	os.WriteFile(path, data, 0600)
+}
+
+func setNames(set map[string]struct{}) []string {
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+	return names
+}
+
+// completionScript returns the shell completion script for shell. Command
+// names and descriptions come from the registry; arguments are completed
+// by calling back into "pokedexcli __complete".
+func completionScript(shell string, cmdMap map[string]cliCommand) (string, error) {
+	var names []string
+	for name := range cmdMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var script strings.Builder
+	switch shell {
+	case "bash":
+		script.WriteString("# bash completion for pokedexcli, load with: source <(pokedexcli completion bash)\n")
+		script.WriteString("_pokedexcli() {\n")
+		script.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		script.WriteString("\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+		fmt.Fprintf(&script, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+		script.WriteString("\telse\n")
+		script.WriteString("\t\tCOMPREPLY=($(pokedexcli __complete \"${COMP_WORDS[@]:1:COMP_CWORD-1}\" \"$cur\" 2>/dev/null))\n")
+		script.WriteString("\tfi\n")
+		script.WriteString("}\n")
+		script.WriteString("complete -F _pokedexcli pokedexcli\n")
+	case "zsh":
+		script.WriteString("#compdef pokedexcli\n")
+		script.WriteString("# zsh completion for pokedexcli, load with: source <(pokedexcli completion zsh)\n")
+		script.WriteString("_pokedexcli() {\n")
+		script.WriteString("\tif (( CURRENT == 2 )); then\n")
+		script.WriteString("\t\tlocal -a commands\n")
+		script.WriteString("\t\tcommands=(\n")
+		for _, name := range names {
+			entry := name + ":" + strings.ReplaceAll(cmdMap[name].description, ":", "\\:")
+			fmt.Fprintf(&script, "\t\t\t%s\n", shellQuote(entry))
+		}
+		script.WriteString("\t\t)\n")
+		script.WriteString("\t\t_describe 'command' commands\n")
+		script.WriteString("\telse\n")
+		script.WriteString("\t\tlocal -a names\n")
+		script.WriteString("\t\tnames=(${(f)\"$(pokedexcli __complete \"${(@)words[2,CURRENT-1]}\" \"${words[CURRENT]}\" 2>/dev/null)\"})\n")
+		script.WriteString("\t\tcompadd -a names\n")
+		script.WriteString("\tfi\n")
+		script.WriteString("}\n")
+		script.WriteString("compdef _pokedexcli pokedexcli\n")
+	case "fish":
+		script.WriteString("# fish completion for pokedexcli, load with: pokedexcli completion fish | source\n")
+		script.WriteString("complete -c pokedexcli -f\n")
+		for _, name := range names {
+			fmt.Fprintf(&script, "complete -c pokedexcli -n __fish_use_subcommand -a %s -d %s\n", name, fishQuote(cmdMap[name].description))
+		}
+		script.WriteString("complete -c pokedexcli -n 'not __fish_use_subcommand' -a '(pokedexcli __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'\n")
+	default:
+		return "", fmt.Errorf("unknown shell %q, expected bash, zsh or fish", shell)
+	}
+	return script.String(), nil
+}
+
+// shellQuote single-quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes s for fish, which escapes quotes with a backslash.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
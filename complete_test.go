@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompleteWord(t *testing.T) {
+	KNOWN_AREAS["canalave-city-area"] = struct{}{}
+	KNOWN_POKEMON["pikachu"] = struct{}{}
+	KNOWN_POKEMON["pidgey"] = struct{}{}
+	defer delete(KNOWN_AREAS, "canalave-city-area")
+	defer delete(KNOWN_POKEMON, "pikachu")
+	defer delete(KNOWN_POKEMON, "pidgey")
+
+	cases := []struct {
+		words    []string
+		partial  string
+		expected []string
+	}{
+		{words: nil, partial: "ma", expected: []string{"map", "mapb"}},
+		{words: []string{"explore"}, partial: "can", expected: []string{"canalave-city-area"}},
+		{words: []string{"catch"}, partial: "pi", expected: []string{"pidgey", "pikachu"}},
+		{words: []string{"inspect"}, partial: "ca", expected: nil},
+		{words: []string{"map"}, partial: "", expected: nil},
+	}
+	for _, c := range cases {
+		actual := completeWord(c.words, c.partial)
+		if strings.Join(actual, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("%v %q: EXPECTED: %v\tACTUAL: %v", c.words, c.partial, c.expected, actual)
+		}
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	cmdMap := createRegistry()
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := completionScript(shell, cmdMap)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", shell, err)
+			continue
+		}
+		for name := range cmdMap {
+			if !strings.Contains(script, name) {
+				t.Errorf("%s: expected script to offer %s", shell, name)
+			}
+		}
+		if !strings.Contains(script, "pokedexcli __complete") {
+			t.Errorf("%s: expected script to call __complete", shell)
+		}
+	}
+	_, err := completionScript("powershell", cmdMap)
+	if err == nil {
+		t.Errorf("expected an error for an unknown shell")
+	}
+}
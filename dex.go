@@ -0,0 +1,366 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"pokedexcli/internal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// pokedexList is one of the API's pokedexes, national or regional.
+type pokedexList struct {
+	Name           string          `json:"name"`
+	Names          []localizedName `json:"names"`
+	PokemonEntries []struct {
+		EntryNumber    int           `json:"entry_number"`
+		PokemonSpecies namedResource `json:"pokemon_species"`
+	} `json:"pokemon_entries"`
+}
+
+var DEX_CACHE *internal.Cache[string, pokedexList]
+
+// dexEntry is how far along the player is with one species in a pokedex.
+type dexEntry struct {
+	Number  int    `json:"number"`
+	Species string `json:"species"`
+	Status  string `json:"status"`
+}
+
+// dexProgress counts species the way the games do, so caught species are
+// seen too.
+type dexProgress struct {
+	Dex        string     `json:"dex"`
+	Caught     int        `json:"caught"`
+	Seen       int        `json:"seen"`
+	Missing    int        `json:"missing"`
+	Total      int        `json:"total"`
+	Completion float64    `json:"completion"`
+	Entries    []dexEntry `json:"entries"`
+}
+
+// dexEntries lists the species in dex by entry number with their status.
+// caught and seen are keyed by species, since pokemon like
+// pikachu-rock-star count as the species they're a form of.
+func dexEntries(dex pokedexList, caught map[string]bool, seen map[string]bool) dexProgress {
+	progress := dexProgress{Dex: dex.Name, Total: len(dex.PokemonEntries)}
+	for _, entry := range dex.PokemonEntries {
+		e := dexEntry{Number: entry.EntryNumber, Species: entry.PokemonSpecies.Name, Status: "missing"}
+		switch {
+		case caught[e.Species]:
+			e.Status = "caught"
+			progress.Caught++
+			progress.Seen++
+		case seen[e.Species]:
+			e.Status = "seen"
+			progress.Seen++
+		default:
+			progress.Missing++
+		}
+		progress.Entries = append(progress.Entries, e)
+	}
+	sort.SliceStable(progress.Entries, func(a, b int) bool {
+		return progress.Entries[a].Number < progress.Entries[b].Number
+	})
+	if progress.Total > 0 {
+		progress.Completion = float64(progress.Caught) * 100 / float64(progress.Total)
+	}
+	return progress
+}
+
+// playerSpecies returns the species of the pokemon we've caught, and of
+// those we've seen, caught or not. Pokemon only seen while exploring
+// haven't been fetched, so their names stand in for their species.
+func playerSpecies() (map[string]bool, map[string]bool) {
+	caught := make(map[string]bool)
+	seen := make(map[string]bool)
+	species := func(name string) string {
+		if mon, exists := POKEMON[name]; exists {
+			return mon.Species.Name
+		}
+		return name
+	}
+	for name := range POKEMON {
+		seen[species(name)] = true
+		if _, exists := CAUGHT[name]; exists {
+			caught[species(name)] = true
+		}
+	}
+	for name := range SEEN {
+		seen[species(name)] = true
+	}
+	return caught, seen
+}
+
+func getDex(ctx context.Context, name string) (pokedexList, internal.LookupResult, error) {
+	dex, lookup, err := DEX_CACHE.Fetch(ctx, name, POKEMON_TTL, POKEMON_STALE, func(ctx context.Context) (pokedexList, error) {
+		var dex pokedexList
+		return dex, API.GetJSON(ctx, fmt.Sprintf("https://pokeapi.co/api/v2/pokedex/%s", name), &dex)
+	})
+	noteStale(lookup, name)
+	return dex, lookup, err
+}
+
+// showDex prints progress through the named pokedex, or with missing only
+// the species still to catch. Most regions have no pokedex of their own
+// name, such as sinnoh's original-sinnoh and extended-sinnoh, so a region
+// stands for the first of its pokedexes.
+func showDex(ctx context.Context, name string, missing bool) error {
+	dex, lookup, err := getDex(ctx, name)
+	if err != nil {
+		return err
+	}
+	if lookup == internal.KnownMissing {
+		reg, regLookup, err := getRegion(ctx, name)
+		if err != nil {
+			return err
+		}
+		if regLookup == internal.KnownMissing || len(reg.Pokedexes) == 0 {
+			fmt.Printf("Pokedex %s not found, try national or a region such as kanto\n", name)
+			return nil
+		}
+		if len(reg.Pokedexes) > 1 && !OUTPUT_JSON {
+			var others []string
+			for _, p := range reg.Pokedexes[1:] {
+				others = append(others, p.Name)
+			}
+			fmt.Printf("Showing %s, %s also has %s\n", reg.Pokedexes[0].Name, name, strings.Join(others, ", "))
+		}
+		dex, lookup, err = getDex(ctx, reg.Pokedexes[0].Name)
+		if err != nil {
+			return err
+		}
+		if lookup == internal.KnownMissing {
+			fmt.Printf("Pokedex %s not found\n", reg.Pokedexes[0].Name)
+			return nil
+		}
+	}
+	caught, seen := playerSpecies()
+	progress := dexEntries(dex, caught, seen)
+	if missing {
+		var entries []dexEntry
+		for _, e := range progress.Entries {
+			if e.Status == "missing" {
+				entries = append(entries, e)
+			}
+		}
+		progress.Entries = entries
+	}
+	if OUTPUT_JSON {
+		return printJSON(progress)
+	}
+
+	title := pickName(dex.Names, dex.Name)
+	fmt.Printf("%s pokedex: %d seen, %d caught, %d missing (%.1f%% complete)\n", title, progress.Seen, progress.Caught, progress.Missing, progress.Completion)
+	if missing {
+		// there can be hundreds of these, too many to look up names for
+		for _, e := range progress.Entries {
+			fmt.Printf(" #%03d %s\n", e.Number, e.Species)
+		}
+		return nil
+	}
+	var found []dexEntry
+	var species []string
+	for _, e := range progress.Entries {
+		if e.Status != "missing" {
+			found = append(found, e)
+			species = append(species, e.Species)
+		}
+	}
+	for i, name := range localNames(ctx, "pokemon-species", species) {
+		fmt.Printf(" #%03d %s (%s)\n", found[i].Number, name, found[i].Status)
+	}
+	return nil
+}
+
+// caughtRecord is what we note about a pokemon when it's caught.
+type caughtRecord struct {
+	CaughtAt time.Time `json:"caught_at"`
+	Level    int       `json:"level"`
+}
+
+// collectionEntry is a caught pokemon as pokedex lists it.
+type collectionEntry struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Level      int       `json:"level"`
+	BST        int       `json:"bst"`
+	Types      []string  `json:"types"`
+	Generation int       `json:"generation,omitempty"`
+	CaughtAt   time.Time `json:"caught_at"`
+}
+
+var collectionSorts = map[string]func(a, b collectionEntry) int{
+	"id":        func(a, b collectionEntry) int { return cmp.Compare(a.ID, b.ID) },
+	"name":      func(a, b collectionEntry) int { return strings.Compare(a.Name, b.Name) },
+	"caught-at": func(a, b collectionEntry) int { return a.CaughtAt.Compare(b.CaughtAt) },
+	"level":     func(a, b collectionEntry) int { return cmp.Compare(a.Level, b.Level) },
+	"bst":       func(a, b collectionEntry) int { return cmp.Compare(a.BST, b.BST) },
+}
+
+// sortCollection sorts entries by the named key, breaking ties by name so
+// the order is the same every time.
+func sortCollection(entries []collectionEntry, by string) error {
+	compare, exists := collectionSorts[by]
+	if !exists {
+		return fmt.Errorf("can't sort by %q, expected id, name, caught-at, level or bst", by)
+	}
+	slices.SortFunc(entries, collectionSorts["name"])
+	slices.SortStableFunc(entries, compare)
+	return nil
+}
+
+// collectionFilter keeps the entries having every type and coming from
+// every generation it was given.
+type collectionFilter struct {
+	types []string
+	gens  []int
+}
+
+// parseFilters parses --filter values such as type=fire and gen=1.
+func parseFilters(values []string) (collectionFilter, error) {
+	var filter collectionFilter
+	for _, value := range values {
+		key, arg, _ := strings.Cut(strings.ToLower(value), "=")
+		switch key {
+		case "type":
+			filter.types = append(filter.types, arg)
+		case "gen", "generation":
+			gen := parseGeneration(arg)
+			if gen == 0 {
+				return filter, fmt.Errorf("unknown generation %q", arg)
+			}
+			filter.gens = append(filter.gens, gen)
+		default:
+			return filter, fmt.Errorf("can't filter on %q, expected type=<type> or gen=<n>", value)
+		}
+	}
+	return filter, nil
+}
+
+func (filter collectionFilter) matches(entry collectionEntry) bool {
+	for _, t := range filter.types {
+		if !slices.Contains(entry.Types, t) {
+			return false
+		}
+	}
+	for _, gen := range filter.gens {
+		if entry.Generation != gen {
+			return false
+		}
+	}
+	return true
+}
+
+var romanNumerals = []string{"i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix"}
+
+// parseGeneration turns 4, iv or generation-iv into 4, or 0 when it isn't
+// a generation.
+func parseGeneration(value string) int {
+	value = strings.TrimPrefix(value, "generation-")
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n
+	}
+	return slices.Index(romanNumerals, value) + 1
+}
+
+// listCaught lists the caught pokemon, sorted, filtered and a page at a
+// time.
+func listCaught(ctx context.Context, flags flagSet) error {
+	by := "id"
+	if flags.has("sort") {
+		by = flags.get("sort")
+	}
+	filter, err := parseFilters(flags.all("filter"))
+	if err != nil {
+		return err
+	}
+	size, page := 20, 1
+	if flags.has("size") {
+		size, err = strconv.Atoi(flags.get("size"))
+		if err != nil || size < 1 {
+			return fmt.Errorf("invalid page size %q", flags.get("size"))
+		}
+	}
+	if flags.has("page") {
+		page, err = strconv.Atoi(flags.get("page"))
+		if err != nil || page < 1 {
+			return fmt.Errorf("invalid page %q", flags.get("page"))
+		}
+	}
+
+	var entries []collectionEntry
+	for name, record := range CAUGHT {
+		mon := POKEMON[name]
+		entry := collectionEntry{ID: mon.ID, Name: name, Level: record.Level, CaughtAt: record.CaughtAt}
+		for _, stat := range mon.Stats {
+			entry.BST += stat.BaseStat
+		}
+		for _, t := range mon.Types {
+			entry.Types = append(entry.Types, t.Type.Name)
+		}
+		if len(filter.gens) > 0 {
+			species, err := getSpecies(ctx, mon)
+			if err != nil {
+				return err
+			}
+			entry.Generation = parseGeneration(species.Generation.Name)
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	err = sortCollection(entries, by)
+	if err != nil {
+		return err
+	}
+	// JSON gets everything unless a page was asked for
+	pages := 1
+	if !OUTPUT_JSON || flags.has("page") || flags.has("size") {
+		pages = max((len(entries)+size-1)/size, 1)
+		if page > pages {
+			fmt.Printf("There are only %d pages\n", pages)
+			return nil
+		}
+		entries = entries[(page-1)*size : min(page*size, len(entries))]
+	}
+	if OUTPUT_JSON {
+		if entries == nil {
+			entries = []collectionEntry{}
+		}
+		return printJSON(entries)
+	}
+
+	caught, seen := playerSpecies()
+	fmt.Printf("Your Pokedex: %d seen, %d caught\n", len(seen), len(caught))
+	if len(entries) == 0 {
+		fmt.Println(" (nothing caught yet)")
+		return nil
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  #\tname\tlevel\tbst\ttypes\tcaught\n")
+	for i, name := range localNames(ctx, "pokemon-species", names) {
+		entry := entries[i]
+		// pokemon caught without exploring first have no level
+		level := "-"
+		if entry.Level > 0 {
+			level = strconv.Itoa(entry.Level)
+		}
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%d\t%s\t%s\n", entry.ID, name, level, entry.BST, strings.Join(entry.Types, "/"), entry.CaughtAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	if pages > 1 {
+		fmt.Printf("page %d of %d\n", page, pages)
+	}
+	return nil
+}
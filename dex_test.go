@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDexEntries(t *testing.T) {
+	var dex pokedexList
+	err := json.Unmarshal([]byte(`{"name": "kanto", "pokemon_entries": [
+		{"entry_number": 25, "pokemon_species": {"name": "pikachu"}},
+		{"entry_number": 1, "pokemon_species": {"name": "bulbasaur"}},
+		{"entry_number": 4, "pokemon_species": {"name": "charmander"}},
+		{"entry_number": 7, "pokemon_species": {"name": "squirtle"}}
+	]}`), &dex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caught := map[string]bool{"pikachu": true}
+	seen := map[string]bool{"charmander": true}
+	progress := dexEntries(dex, caught, seen)
+
+	expected := []dexEntry{
+		{1, "bulbasaur", "missing"},
+		{4, "charmander", "seen"},
+		{7, "squirtle", "missing"},
+		{25, "pikachu", "caught"},
+	}
+	if len(progress.Entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %v", len(expected), progress.Entries)
+	}
+	for i := range expected {
+		if progress.Entries[i] != expected[i] {
+			t.Errorf("entry %d: expected %v, got %v", i, expected[i], progress.Entries[i])
+		}
+	}
+	if progress.Caught != 1 || progress.Seen != 2 || progress.Missing != 2 || progress.Completion != 25 {
+		t.Errorf("expected 1 caught, 2 seen, 2 missing and 25%%, got %+v", progress)
+	}
+}
+
+func TestPlayerSpecies(t *testing.T) {
+	saved, savedCaught, savedSeen := POKEMON, CAUGHT, SEEN
+	defer func() { POKEMON, CAUGHT, SEEN = saved, savedCaught, savedSeen }()
+
+	var rockStar, pikachu, bulbasaur pokemonEntry
+	rockStar.Species.Name = "pikachu"
+	pikachu.Species.Name = "pikachu"
+	bulbasaur.Species.Name = "bulbasaur"
+	POKEMON = map[string]pokemonEntry{"pikachu-rock-star": rockStar, "pikachu": pikachu, "bulbasaur": bulbasaur}
+	CAUGHT = map[string]caughtRecord{"pikachu-rock-star": {}}
+	SEEN = map[string]map[string]bool{"pidgey": {"viridian-forest-area": true}}
+
+	caught, seen := playerSpecies()
+	if !caught["pikachu"] || len(caught) != 1 {
+		t.Errorf("expected pikachu caught through its form, got %v", caught)
+	}
+	if !seen["pikachu"] || !seen["bulbasaur"] || !seen["pidgey"] || len(seen) != 3 {
+		t.Errorf("expected pikachu, bulbasaur and pidgey seen, got %v", seen)
+	}
+}
+
+func TestSortCollection(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []collectionEntry{
+		{ID: 25, Name: "pikachu", Level: 12, BST: 320, CaughtAt: day.Add(time.Hour)},
+		{ID: 4, Name: "charmander", Level: 12, BST: 309, CaughtAt: day.Add(2 * time.Hour)},
+		{ID: 1, Name: "bulbasaur", Level: 30, BST: 318, CaughtAt: day},
+	}
+	cases := []struct {
+		by       string
+		expected []string
+	}{
+		{"id", []string{"bulbasaur", "charmander", "pikachu"}},
+		{"name", []string{"bulbasaur", "charmander", "pikachu"}},
+		{"caught-at", []string{"bulbasaur", "pikachu", "charmander"}},
+		{"level", []string{"charmander", "pikachu", "bulbasaur"}},
+		{"bst", []string{"charmander", "bulbasaur", "pikachu"}},
+	}
+	for _, c := range cases {
+		err := sortCollection(entries, c.by)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, name := range c.expected {
+			if entries[i].Name != name {
+				t.Errorf("sort by %s: expected %v at %d, got %v", c.by, name, i, entries[i].Name)
+			}
+		}
+	}
+	if sortCollection(entries, "weight") == nil {
+		t.Errorf("expected an error sorting by weight")
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	filter, err := parseFilters([]string{"type=fire", "gen=i"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		entry    collectionEntry
+		expected bool
+	}{
+		{collectionEntry{Name: "charmander", Types: []string{"fire"}, Generation: 1}, true},
+		{collectionEntry{Name: "cyndaquil", Types: []string{"fire"}, Generation: 2}, false},
+		{collectionEntry{Name: "bulbasaur", Types: []string{"grass", "poison"}, Generation: 1}, false},
+	}
+	for _, c := range cases {
+		if filter.matches(c.entry) != c.expected {
+			t.Errorf("%s: expected match %v", c.entry.Name, c.expected)
+		}
+	}
+	for _, bad := range []string{"gen=x", "color=red"} {
+		if _, err := parseFilters([]string{bad}); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestParseGeneration(t *testing.T) {
+	cases := map[string]int{"4": 4, "iv": 4, "generation-iv": 4, "generation-ix": 9, "0": 0, "fire": 0}
+	for value, expected := range cases {
+		if actual := parseGeneration(value); actual != expected {
+			t.Errorf("%s: expected %d, got %d", value, expected, actual)
+		}
+	}
+}
+
+func TestShowDexRegion(t *testing.T) {
+	useTestMapAPI(t)
+	API.HTTPClient = &http.Client{Transport: apiTransport{
+		"https://pokeapi.co/api/v2/region/sinnoh":           `{"name": "sinnoh", "pokedexes": [{"name": "original-sinnoh"}, {"name": "extended-sinnoh"}]}`,
+		"https://pokeapi.co/api/v2/region/hisui":            `{"name": "hisui", "pokedexes": []}`,
+		"https://pokeapi.co/api/v2/pokedex/original-sinnoh": `{"name": "original-sinnoh", "pokemon_entries": [{"entry_number": 1, "pokemon_species": {"name": "turtwig"}}]}`,
+	}}
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "original-sinnoh", expected: "original-sinnoh pokedex: 0 seen, 0 caught, 1 missing"},
+		{name: "sinnoh", expected: "Showing original-sinnoh, sinnoh also has extended-sinnoh\noriginal-sinnoh pokedex"},
+		{name: "hisui", expected: "Pokedex hisui not found"},
+		{name: "nowhere", expected: "Pokedex nowhere not found"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var err error
+			output := captureStdout(t, func() {
+				err = showDex(context.Background(), c.name, false)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(output, c.expected) {
+				t.Errorf("expected %q, got %q", c.expected, output)
+			}
+		})
+	}
+}
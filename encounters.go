@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// encounterEvent is a line of the encounter log: a pokemon seen while
+// exploring, a ball thrown at one that it was caught by or escaped, or a
+// pokemon traded away or received.
+type encounterEvent struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Pokemon string    `json:"pokemon"`
+	Area    string    `json:"area,omitempty"`
+	Game    string    `json:"game,omitempty"`
+}
+
+var encounterEvents = []string{"seen", "caught", "escaped", "traded", "received"}
+
+// SEEN holds the pokemon we've come across, by name, with the areas we've
+// seen them in. It's built from the encounter log at startup.
+var SEEN = make(map[string]map[string]bool)
+
+// CURRENT_AREA is the area explored last, where catches are logged as
+// happening.
+var CURRENT_AREA string
+
+func encounterLogPath() string {
+	return trainerPath("encounters.jsonl")
+}
+
+// recordEncounter appends event to the encounter log. Like the history
+// this is best effort; a log we can't write doesn't stop the game.
+func recordEncounter(event encounterEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	noteSeen(event)
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	path := encounterLogPath()
+	os.MkdirAll(filepath.Dir(path), 0700)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		verbosef("could not write the encounter log: %v", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(data, '\n'))
+}
+
+func noteSeen(event encounterEvent) {
+	if SEEN[event.Pokemon] == nil {
+		SEEN[event.Pokemon] = make(map[string]bool)
+	}
+	SEEN[event.Pokemon][event.Area] = true
+}
+
+// recordSightings logs the pokemon seen exploring area, each only the first
+// time it's seen there.
+func recordSightings(area string, pokemon []string) {
+	CURRENT_AREA = area
+	for _, name := range pokemon {
+		if !SEEN[name][area] {
+			recordEncounter(encounterEvent{Event: "seen", Pokemon: name, Area: area, Game: GAME})
+		}
+	}
+}
+
+// readEncounterLog reads the whole log, skipping lines it can't make
+// sense of. No log yet is the same as an empty one.
+func readEncounterLog() ([]encounterEvent, error) {
+	file, err := os.Open(encounterLogPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var events []encounterEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event encounterEvent
+		if json.Unmarshal(scanner.Bytes(), &event) == nil && event.Pokemon != "" {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func loadEncounterLog() {
+	events, err := readEncounterLog()
+	if err != nil {
+		verbosef("could not read the encounter log: %v", err)
+	}
+	for _, event := range events {
+		noteSeen(event)
+	}
+}
+
+// encounterQuery picks events out of the log for the log command.
+type encounterQuery struct {
+	pokemon string
+	event   string
+	area    string
+	since   time.Time
+	limit   int
+}
+
+// filter returns the last limit events matching the query, oldest first.
+func (query encounterQuery) filter(events []encounterEvent) []encounterEvent {
+	var matches []encounterEvent
+	for _, event := range events {
+		if (query.pokemon != "" && event.Pokemon != query.pokemon) ||
+			(query.event != "" && event.Event != query.event) ||
+			(query.area != "" && event.Area != query.area) ||
+			event.Time.Before(query.since) {
+			continue
+		}
+		matches = append(matches, event)
+	}
+	if query.limit > 0 && len(matches) > query.limit {
+		matches = matches[len(matches)-query.limit:]
+	}
+	return matches
+}
+
+// parseSince reads --since as either a duration back from now, such as
+// 36h, or a date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return day, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a duration such as 24h or a date such as 2024-05-01", value)
+}
+
+func encounterLog(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args)
+	query := encounterQuery{event: flags.get("event"), area: flags.get("area"), limit: 20}
+	if len(words) > 0 {
+		query.pokemon = resolveName(words)
+	}
+	if query.event != "" && !slices.Contains(encounterEvents, query.event) {
+		return fmt.Errorf("unknown event %q, expected one of %s", query.event, strings.Join(encounterEvents, ", "))
+	}
+	if flags.has("since") {
+		since, err := parseSince(flags.get("since"), time.Now())
+		if err != nil {
+			return err
+		}
+		query.since = since
+	}
+	if flags.has("limit") {
+		n, err := strconv.Atoi(flags.get("limit"))
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid limit %q", flags.get("limit"))
+		}
+		query.limit = n
+	}
+	events, err := readEncounterLog()
+	if err != nil {
+		return err
+	}
+	events = query.filter(events)
+
+	if OUTPUT_JSON {
+		if events == nil {
+			events = []encounterEvent{}
+		}
+		return printJSON(events)
+	}
+	if len(events) == 0 {
+		fmt.Println("No encounters logged")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, event := range events {
+		where := event.Area
+		if where == "" {
+			where = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", event.Time.Local().Format("2006-01-02 15:04"), event.Event, event.Pokemon, where)
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncounterLog(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	savedSeen, savedArea := SEEN, CURRENT_AREA
+	defer func() { SEEN, CURRENT_AREA = savedSeen, savedArea }()
+	SEEN = make(map[string]map[string]bool)
+
+	recordSightings("viridian-forest-area", []string{"pikachu", "caterpie"})
+	recordSightings("viridian-forest-area", []string{"pikachu", "weedle"})
+	recordEncounter(encounterEvent{Event: "escaped", Pokemon: "pikachu", Area: CURRENT_AREA})
+	events, err := readEncounterLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"seen pikachu", "seen caterpie", "seen weedle", "escaped pikachu"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.Event+" "+event.Pokemon != expected[i] || event.Area != "viridian-forest-area" {
+			t.Errorf("event %d: expected %s in viridian-forest-area, got %+v", i, expected[i], event)
+		}
+	}
+
+	SEEN = make(map[string]map[string]bool)
+	loadEncounterLog()
+	if !SEEN["weedle"]["viridian-forest-area"] || len(SEEN) != 3 {
+		t.Errorf("expected the log to be loaded into SEEN, got %v", SEEN)
+	}
+}
+
+func TestEncounterQuery(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []encounterEvent{
+		{Time: day, Event: "seen", Pokemon: "pikachu", Area: "viridian-forest-area"},
+		{Time: day.Add(time.Hour), Event: "escaped", Pokemon: "pikachu", Area: "viridian-forest-area"},
+		{Time: day.Add(2 * time.Hour), Event: "caught", Pokemon: "pikachu"},
+		{Time: day.Add(3 * time.Hour), Event: "caught", Pokemon: "zubat", Area: "mt-moon-1f"},
+	}
+	cases := []struct {
+		query    encounterQuery
+		expected int
+	}{
+		{encounterQuery{}, 4},
+		{encounterQuery{pokemon: "pikachu"}, 3},
+		{encounterQuery{event: "caught"}, 2},
+		{encounterQuery{area: "viridian-forest-area"}, 2},
+		{encounterQuery{since: day.Add(90 * time.Minute)}, 2},
+		{encounterQuery{limit: 1}, 1},
+	}
+	for _, c := range cases {
+		actual := c.query.filter(events)
+		if len(actual) != c.expected {
+			t.Errorf("%+v: expected %d events, got %v", c.query, c.expected, actual)
+		}
+	}
+	last := encounterQuery{limit: 1}.filter(events)
+	if len(last) == 1 && last[0].Pokemon != "zubat" {
+		t.Errorf("expected the limit to keep the latest event, got %v", last)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	since, err := parseSince("36h", now)
+	if err != nil || !since.Equal(now.Add(-36*time.Hour)) {
+		t.Errorf("expected 36 hours ago, got %v, %v", since, err)
+	}
+	since, err = parseSince("2026-03-01", now)
+	if err != nil || !since.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("expected the start of 2026-03-01, got %v, %v", since, err)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Errorf("expected an error for yesterday")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// versionEncounters is how a pokemon can be met in an area in one game,
+// as both the area and the pokemon encounter endpoints list it.
+type versionEncounters struct {
+	EncounterDetails []struct {
+		Chance          int             `json:"chance"`
+		ConditionValues []namedResource `json:"condition_values"`
+		MaxLevel        int             `json:"max_level"`
+		Method          namedResource   `json:"method"`
+		MinLevel        int             `json:"min_level"`
+	} `json:"encounter_details"`
+	MaxChance int           `json:"max_chance"`
+	Version   namedResource `json:"version"`
+}
+
+// encounterRow is one way to meet a pokemon in one game. Encounter slots
+// that differ only in chance or level, such as the several surfing slots
+// most areas have, are added together.
+type encounterRow struct {
+	Version    string `json:"version"`
+	Pokemon    string `json:"pokemon,omitempty"`
+	Area       string `json:"area,omitempty"`
+	Method     string `json:"method"`
+	Conditions string `json:"conditions,omitempty"`
+	Chance     int    `json:"chance"`
+	MinLevel   int    `json:"min_level"`
+	MaxLevel   int    `json:"max_level"`
+}
+
+// encounterRows lists the area's encounters, keeping to version and method
+// when they're set. Rows come in the order the API lists versions, then
+// pokemon.
+func encounterRows(lal locationAreaLocation, version string, method string) []encounterRow {
+	var rows encounterRowSet
+	for _, enc := range lal.PokemonEncounters {
+		rows.add(encounterRow{Pokemon: enc.Pokemon.Name}, enc.VersionDetails, version, method)
+	}
+	return rows.list()
+}
+
+// encounterRowSet gathers encounter rows by version.
+type encounterRowSet struct {
+	versions  []string
+	byVersion map[string][]encounterRow
+}
+
+// add adds the encounters in vds matching version and method, as rows
+// naming what base names.
+func (set *encounterRowSet) add(base encounterRow, vds []versionEncounters, version string, method string) {
+	if set.byVersion == nil {
+		set.byVersion = make(map[string][]encounterRow)
+	}
+	for _, vd := range vds {
+		if version != "" && vd.Version.Name != version {
+			continue
+		}
+		for _, detail := range vd.EncounterDetails {
+			if method != "" && detail.Method.Name != method {
+				continue
+			}
+			var conditions []string
+			for _, condition := range detail.ConditionValues {
+				conditions = append(conditions, condition.Name)
+			}
+			row := base
+			row.Version = vd.Version.Name
+			row.Method = detail.Method.Name
+			row.Conditions = strings.Join(conditions, ", ")
+			rows, seen := set.byVersion[row.Version]
+			if !seen {
+				set.versions = append(set.versions, row.Version)
+			}
+			i := slices.IndexFunc(rows, func(r encounterRow) bool {
+				return r.Pokemon == row.Pokemon && r.Area == row.Area && r.Method == row.Method && r.Conditions == row.Conditions
+			})
+			if i < 0 {
+				row.MinLevel, row.MaxLevel = detail.MinLevel, detail.MaxLevel
+				rows = append(rows, row)
+				i = len(rows) - 1
+			}
+			rows[i].Chance += detail.Chance
+			rows[i].MinLevel = min(rows[i].MinLevel, detail.MinLevel)
+			rows[i].MaxLevel = max(rows[i].MaxLevel, detail.MaxLevel)
+			set.byVersion[row.Version] = rows
+		}
+	}
+}
+
+func (set *encounterRowSet) list() []encounterRow {
+	var rows []encounterRow
+	for _, v := range set.versions {
+		rows = append(rows, set.byVersion[v]...)
+	}
+	return rows
+}
+
+// encounterLevel picks a level for name met in lal, between the lowest and
+// highest it's met at there in version, or in any game when version is "".
+// It's 0 when name isn't met there.
+func encounterLevel(lal locationAreaLocation, name string, version string) int {
+	low, high := 0, 0
+	for _, row := range encounterRows(lal, version, "") {
+		if row.Pokemon != name {
+			continue
+		}
+		if low == 0 || row.MinLevel < low {
+			low = row.MinLevel
+		}
+		high = max(high, row.MaxLevel)
+	}
+	if low <= 0 {
+		return 0
+	}
+	return low + rand.Intn(max(high-low, 0)+1)
+}
+
+// printEncounterTable prints rows as a table for each version. Rows from
+// where name areas instead of pokemon, numbered for where --explore.
+func printEncounterTable(rows []encounterRow) {
+	areaNumbers := make(map[string]int)
+	for i, area := range encounterAreas(rows) {
+		areaNumbers[area] = i + 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, row := range rows {
+		if i == 0 || rows[i-1].Version != row.Version {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n", row.Version)
+			if row.Area != "" {
+				fmt.Fprintf(w, "  #\tarea\tmethod\tchance\tlevels\tconditions\n")
+			} else {
+				fmt.Fprintf(w, "  pokemon\tmethod\tchance\tlevels\tconditions\n")
+			}
+		}
+		levels := fmt.Sprint(row.MinLevel)
+		if row.MaxLevel != row.MinLevel {
+			levels = fmt.Sprintf("%d-%d", row.MinLevel, row.MaxLevel)
+		}
+		if row.Area != "" {
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%d%%\t%s\t%s\n", areaNumbers[row.Area], row.Area, row.Method, row.Chance, levels, row.Conditions)
+		} else {
+			fmt.Fprintf(w, "  %s\t%s\t%d%%\t%s\t%s\n", row.Pokemon, row.Method, row.Chance, levels, row.Conditions)
+		}
+	}
+	w.Flush()
+}
+
+// encounterAreas lists the areas rows name, in the order they first come.
+func encounterAreas(rows []encounterRow) []string {
+	var areas []string
+	for _, row := range rows {
+		if row.Area != "" && !slices.Contains(areas, row.Area) {
+			areas = append(areas, row.Area)
+		}
+	}
+	return areas
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testArea = `{
+	"name": "canalave-city-area",
+	"location": {"name": "canalave-city"},
+	"pokemon_encounters": [
+		{
+			"pokemon": {"name": "tentacool"},
+			"version_details": [
+				{
+					"version": {"name": "diamond"},
+					"encounter_details": [
+						{"chance": 60, "min_level": 20, "max_level": 30, "method": {"name": "surf"}, "condition_values": []},
+						{"chance": 30, "min_level": 20, "max_level": 30, "method": {"name": "surf"}, "condition_values": []},
+						{"chance": 40, "min_level": 15, "max_level": 15, "method": {"name": "good-rod"}, "condition_values": []}
+					]
+				},
+				{
+					"version": {"name": "platinum"},
+					"encounter_details": [
+						{"chance": 60, "min_level": 20, "max_level": 30, "method": {"name": "surf"}, "condition_values": []}
+					]
+				}
+			]
+		},
+		{
+			"pokemon": {"name": "hoothoot"},
+			"version_details": [
+				{
+					"version": {"name": "diamond"},
+					"encounter_details": [
+						{"chance": 10, "min_level": 12, "max_level": 14, "method": {"name": "walk"}, "condition_values": [{"name": "time-night"}]}
+					]
+				}
+			]
+		}
+	]
+}`
+
+func TestEncounterRows(t *testing.T) {
+	var lal locationAreaLocation
+	if err := json.Unmarshal([]byte(testArea), &lal); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		version  string
+		method   string
+		expected []encounterRow
+	}{
+		{
+			name: "all encounters, slots added together",
+			expected: []encounterRow{
+				{Version: "diamond", Pokemon: "tentacool", Method: "surf", Chance: 90, MinLevel: 20, MaxLevel: 30},
+				{Version: "diamond", Pokemon: "tentacool", Method: "good-rod", Chance: 40, MinLevel: 15, MaxLevel: 15},
+				{Version: "diamond", Pokemon: "hoothoot", Method: "walk", Conditions: "time-night", Chance: 10, MinLevel: 12, MaxLevel: 14},
+				{Version: "platinum", Pokemon: "tentacool", Method: "surf", Chance: 60, MinLevel: 20, MaxLevel: 30},
+			},
+		},
+		{
+			name:    "one version",
+			version: "platinum",
+			expected: []encounterRow{
+				{Version: "platinum", Pokemon: "tentacool", Method: "surf", Chance: 60, MinLevel: 20, MaxLevel: 30},
+			},
+		},
+		{
+			name:   "one method",
+			method: "walk",
+			expected: []encounterRow{
+				{Version: "diamond", Pokemon: "hoothoot", Method: "walk", Conditions: "time-night", Chance: 10, MinLevel: 12, MaxLevel: 14},
+			},
+		},
+		{
+			name:    "nothing matches",
+			version: "platinum",
+			method:  "walk",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := encounterRows(lal, c.version, c.method)
+			if len(rows) != len(c.expected) {
+				t.Fatalf("expected %v rows, got %+v", len(c.expected), rows)
+			}
+			for i := range rows {
+				if rows[i] != c.expected[i] {
+					t.Errorf("row %v: expected %+v, got %+v", i, c.expected[i], rows[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEncounterLevel(t *testing.T) {
+	var lal locationAreaLocation
+	if err := json.Unmarshal([]byte(testArea), &lal); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		pokemon string
+		version string
+		low     int
+		high    int
+	}{
+		{name: "every method", pokemon: "tentacool", version: "diamond", low: 15, high: 30},
+		{name: "one version", pokemon: "tentacool", version: "platinum", low: 20, high: 30},
+		{name: "any version", pokemon: "hoothoot", low: 12, high: 14},
+		{name: "not met in the version", pokemon: "hoothoot", version: "platinum"},
+		{name: "not met here", pokemon: "pikachu"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for range 50 {
+				level := encounterLevel(lal, c.pokemon, c.version)
+				if level < c.low || level > c.high {
+					t.Fatalf("expected a level from %d to %d, got %d", c.low, c.high, level)
+				}
+			}
+		})
+	}
+}
+
+func TestEncounterAreas(t *testing.T) {
+	var encounters []areaEncounters
+	err := json.Unmarshal([]byte(`[
+		{"location_area": {"name": "sinnoh-route-204-south-towards-jubilife-city"}, "version_details": [
+			{"version": {"name": "diamond"}, "encounter_details": [{"chance": 10, "min_level": 4, "max_level": 5, "method": {"name": "walk"}}]}
+		]},
+		{"location_area": {"name": "trophy-garden-area"}, "version_details": [
+			{"version": {"name": "platinum"}, "encounter_details": [{"chance": 20, "min_level": 16, "max_level": 16, "method": {"name": "walk"}}]},
+			{"version": {"name": "diamond"}, "encounter_details": [{"chance": 20, "min_level": 16, "max_level": 16, "method": {"name": "walk"}}]}
+		]}
+	]`), &encounters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set encounterRowSet
+	for _, enc := range encounters {
+		set.add(encounterRow{Area: enc.LocationArea.Name}, enc.VersionDetails, "", "")
+	}
+	rows := set.list()
+	if len(rows) != 3 || rows[0].Version != "diamond" || rows[1].Version != "diamond" || rows[2].Version != "platinum" {
+		t.Errorf("expected rows grouped by version, got %+v", rows)
+	}
+	areas := encounterAreas(rows)
+	if len(areas) != 2 || areas[0] != "sinnoh-route-204-south-towards-jubilife-city" || areas[1] != "trophy-garden-area" {
+		t.Errorf("expected both areas in order, got %v", areas)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pokedexcli/internal"
+)
+
+// GAME is the game version, such as heartgold, that encounters, moves,
+// sprites and catching are limited to, or "" for every game.
+var GAME string
+
+// GAME_INFO is what we've looked up about GAME.
+var GAME_INFO *gameInfo
+
+type gameInfo struct {
+	Version      string
+	VersionGroup string
+	Generation   string
+	// Species is every species in the game's regional pokedexes, nil if
+	// it has none, in which case anything can be caught.
+	Species map[string]bool
+}
+
+// activeGame returns what we know about GAME, looking it up the first time
+// it's needed, or nil when no game is set.
+func activeGame(ctx context.Context) (*gameInfo, error) {
+	if GAME == "" {
+		return nil, nil
+	}
+	if GAME_INFO != nil && GAME_INFO.Version == GAME {
+		return GAME_INFO, nil
+	}
+	var version struct {
+		Name         string        `json:"name"`
+		VersionGroup namedResource `json:"version_group"`
+	}
+	err := API.GetJSON(ctx, fmt.Sprintf("https://pokeapi.co/api/v2/version/%s", GAME), &version)
+	if errors.Is(err, internal.ErrNotFound) {
+		return nil, fmt.Errorf("unknown game %q, try set game off or a version such as heartgold", GAME)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var group struct {
+		Generation namedResource   `json:"generation"`
+		Pokedexes  []namedResource `json:"pokedexes"`
+	}
+	err = API.GetJSON(ctx, version.VersionGroup.URL, &group)
+	if err != nil {
+		return nil, err
+	}
+	info := &gameInfo{Version: version.Name, VersionGroup: version.VersionGroup.Name, Generation: group.Generation.Name}
+	for _, dex := range group.Pokedexes {
+		var pokedex struct {
+			PokemonEntries []struct {
+				PokemonSpecies namedResource `json:"pokemon_species"`
+			} `json:"pokemon_entries"`
+		}
+		err = API.GetJSON(ctx, dex.URL, &pokedex)
+		if err != nil {
+			return nil, err
+		}
+		if info.Species == nil {
+			info.Species = make(map[string]bool)
+		}
+		for _, entry := range pokedex.PokemonEntries {
+			info.Species[entry.PokemonSpecies.Name] = true
+		}
+	}
+	GAME_INFO = info
+	return info, nil
+}
+
+// catchable reports whether mon can be caught in the active game.
+func (info *gameInfo) catchable(mon pokemonEntry) bool {
+	return info == nil || info.Species == nil || info.Species[mon.Species.Name]
+}
+
+// gameSprites returns the front, front shiny, back and back shiny sprites
+// mon has from the game, if the API has any.
+func gameSprites(mon pokemonEntry, info *gameInfo) ([4]string, bool) {
+	v := mon.Sprites.Versions
+	var urls [4]string
+	switch info.VersionGroup {
+	case "red-blue":
+		urls = [4]string{v.GenerationI.RedBlue.FrontTransparent, "", v.GenerationI.RedBlue.BackTransparent, ""}
+	case "yellow":
+		urls = [4]string{v.GenerationI.Yellow.FrontTransparent, "", v.GenerationI.Yellow.BackTransparent, ""}
+	case "gold-silver":
+		if info.Version == "silver" {
+			urls = [4]string{v.GenerationIi.Silver.FrontTransparent, v.GenerationIi.Silver.FrontShiny, v.GenerationIi.Silver.BackDefault, v.GenerationIi.Silver.BackShiny}
+		} else {
+			urls = [4]string{v.GenerationIi.Gold.FrontTransparent, v.GenerationIi.Gold.FrontShiny, v.GenerationIi.Gold.BackDefault, v.GenerationIi.Gold.BackShiny}
+		}
+	case "crystal":
+		urls = [4]string{v.GenerationIi.Crystal.FrontTransparent, v.GenerationIi.Crystal.FrontShinyTransparent, v.GenerationIi.Crystal.BackTransparent, v.GenerationIi.Crystal.BackShinyTransparent}
+	case "ruby-sapphire":
+		urls = [4]string{v.GenerationIii.RubySapphire.FrontDefault, v.GenerationIii.RubySapphire.FrontShiny, v.GenerationIii.RubySapphire.BackDefault, v.GenerationIii.RubySapphire.BackShiny}
+	case "emerald":
+		urls = [4]string{v.GenerationIii.Emerald.FrontDefault, v.GenerationIii.Emerald.FrontShiny, "", ""}
+	case "firered-leafgreen":
+		urls = [4]string{v.GenerationIii.FireredLeafgreen.FrontDefault, v.GenerationIii.FireredLeafgreen.FrontShiny, v.GenerationIii.FireredLeafgreen.BackDefault, v.GenerationIii.FireredLeafgreen.BackShiny}
+	case "diamond-pearl":
+		urls = [4]string{v.GenerationIv.DiamondPearl.FrontDefault, v.GenerationIv.DiamondPearl.FrontShiny, v.GenerationIv.DiamondPearl.BackDefault, v.GenerationIv.DiamondPearl.BackShiny}
+	case "platinum":
+		urls = [4]string{v.GenerationIv.Platinum.FrontDefault, v.GenerationIv.Platinum.FrontShiny, v.GenerationIv.Platinum.BackDefault, v.GenerationIv.Platinum.BackShiny}
+	case "heartgold-soulsilver":
+		urls = [4]string{v.GenerationIv.HeartgoldSoulsilver.FrontDefault, v.GenerationIv.HeartgoldSoulsilver.FrontShiny, v.GenerationIv.HeartgoldSoulsilver.BackDefault, v.GenerationIv.HeartgoldSoulsilver.BackShiny}
+	case "black-white", "black-2-white-2":
+		urls = [4]string{v.GenerationV.BlackWhite.FrontDefault, v.GenerationV.BlackWhite.FrontShiny, v.GenerationV.BlackWhite.BackDefault, v.GenerationV.BlackWhite.BackShiny}
+	case "x-y":
+		urls = [4]string{v.GenerationVi.XY.FrontDefault, v.GenerationVi.XY.FrontShiny, "", ""}
+	case "omega-ruby-alpha-sapphire":
+		urls = [4]string{v.GenerationVi.OmegarubyAlphasapphire.FrontDefault, v.GenerationVi.OmegarubyAlphasapphire.FrontShiny, "", ""}
+	case "ultra-sun-ultra-moon":
+		urls = [4]string{v.GenerationVii.UltraSunUltraMoon.FrontDefault, v.GenerationVii.UltraSunUltraMoon.FrontShiny, "", ""}
+	default:
+		return urls, false
+	}
+	return urls, urls[0] != ""
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCatchable(t *testing.T) {
+	var pikachu, bulbasaur pokemonEntry
+	pikachu.Species.Name = "pikachu"
+	bulbasaur.Species.Name = "bulbasaur"
+
+	var noGame *gameInfo
+	if !noGame.catchable(bulbasaur) {
+		t.Errorf("expected anything to be catchable with no game set")
+	}
+	platinum := &gameInfo{Version: "platinum", VersionGroup: "platinum", Species: map[string]bool{"pikachu": true}}
+	if !platinum.catchable(pikachu) || platinum.catchable(bulbasaur) {
+		t.Errorf("expected only pikachu to be catchable in platinum")
+	}
+	colosseum := &gameInfo{Version: "colosseum", VersionGroup: "colosseum"}
+	if !colosseum.catchable(bulbasaur) {
+		t.Errorf("expected anything to be catchable in a game without a pokedex")
+	}
+}
+
+func TestGameSprites(t *testing.T) {
+	var mon pokemonEntry
+	mon.Sprites.Versions.GenerationIv.HeartgoldSoulsilver.FrontDefault = "hgss-front"
+	mon.Sprites.Versions.GenerationIv.HeartgoldSoulsilver.BackShiny = "hgss-back-shiny"
+	mon.Sprites.Versions.GenerationIi.Silver.FrontTransparent = "silver-front"
+
+	cases := []struct {
+		info     gameInfo
+		expected [4]string
+		ok       bool
+	}{
+		{info: gameInfo{Version: "soulsilver", VersionGroup: "heartgold-soulsilver"}, expected: [4]string{"hgss-front", "", "", "hgss-back-shiny"}, ok: true},
+		{info: gameInfo{Version: "silver", VersionGroup: "gold-silver"}, expected: [4]string{"silver-front", "", "", ""}, ok: true},
+		{info: gameInfo{Version: "gold", VersionGroup: "gold-silver"}},
+		{info: gameInfo{Version: "sword", VersionGroup: "sword-shield"}},
+	}
+	for _, c := range cases {
+		urls, ok := gameSprites(mon, &c.info)
+		if ok != c.ok || (ok && urls != c.expected) {
+			t.Errorf("%s: expected %v, %v, got %v, %v", c.info.Version, c.expected, c.ok, urls, ok)
+		}
+	}
+}
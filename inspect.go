@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type pokemonSpecies struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	FlavorTextEntries []struct {
+		FlavorText string `json:"flavor_text"`
+		Language   struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"language"`
+		Version struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"version"`
+	} `json:"flavor_text_entries"`
+	Genera []struct {
+		Genus    string `json:"genus"`
+		Language struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"language"`
+	} `json:"genera"`
+	Generation struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	} `json:"generation"`
+	Names          []localizedName `json:"names"`
+	EvolutionChain struct {
+		URL string `json:"url"`
+	} `json:"evolution_chain"`
+	Varieties []struct {
+		IsDefault bool          `json:"is_default"`
+		Pokemon   namedResource `json:"pokemon"`
+	} `json:"varieties"`
+}
+
+// inspectReport is everything inspect knows how to show. Sections that
+// weren't asked for are left empty so they drop out of the JSON output.
+type inspectReport struct {
+	Name         string           `json:"name"`
+	Height       int              `json:"height,omitempty"`
+	Weight       int              `json:"weight,omitempty"`
+	Stats        map[string]int   `json:"stats,omitempty"`
+	Types        []string         `json:"types,omitempty"`
+	Abilities    []inspectAbility `json:"abilities,omitempty"`
+	VersionGroup string           `json:"version_group,omitempty"`
+	Moves        []inspectMove    `json:"moves,omitempty"`
+	Species      *inspectSpecies  `json:"species,omitempty"`
+}
+
+type inspectAbility struct {
+	Name   string `json:"name"`
+	Hidden bool   `json:"hidden"`
+}
+
+type inspectMove struct {
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+type inspectSpecies struct {
+	Genus      string `json:"genus"`
+	FlavorText string `json:"flavor_text"`
+	Version    string `json:"version"`
+}
+
+var inspectSections = []string{"abilities", "moves", "species", "all"}
+
+var baseStats = []string{"hp", "attack", "defense", "special-attack", "special-defense", "speed"}
+
+func inspect(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args, "sprite", "shiny", "back")
+	if len(words) < 1 {
+		fmt.Println("Usage: inspect <pokemon name> [--section abilities|moves|species|all] [--version-group <name>] [--sprite]")
+		return nil
+	}
+	name := resolveName(words)
+	section := flags.get("section")
+	if section != "" && !slices.Contains(inspectSections, section) {
+		return fmt.Errorf("unknown section %q, expected one of %s", section, strings.Join(inspectSections, ", "))
+	}
+
+	mon, exists := POKEMON[name]
+	if !exists {
+		if suggestion := didYouMeanCaught(name); suggestion != "" {
+			fmt.Printf("Unknown pokemon %s%s\n", name, suggestion)
+			return nil
+		}
+		fmt.Printf("Unknown pokemon, try catching one with catch %s\n", name)
+		return nil
+	}
+
+	report := inspectReport{Name: name}
+	if section == "" || section == "all" {
+		report.Height = mon.Height
+		report.Weight = mon.Weight
+		report.Stats = make(map[string]int)
+		for _, stat := range mon.Stats {
+			if slices.Contains(baseStats, stat.Stat.Name) {
+				report.Stats[stat.Stat.Name] = stat.BaseStat
+			}
+		}
+		for _, pType := range mon.Types {
+			report.Types = append(report.Types, pType.Type.Name)
+		}
+	}
+	if section == "abilities" || section == "all" {
+		for _, ability := range mon.Abilities {
+			report.Abilities = append(report.Abilities, inspectAbility{Name: ability.Ability.Name, Hidden: ability.IsHidden})
+		}
+	}
+	if section == "moves" || section == "all" {
+		versionGroup := flags.get("version-group")
+		if versionGroup == "" {
+			info, err := activeGame(ctx)
+			if err != nil {
+				return err
+			}
+			if info != nil {
+				versionGroup = info.VersionGroup
+			}
+		}
+		report.VersionGroup, report.Moves = levelUpMoves(mon, versionGroup)
+	}
+	if section == "species" || section == "all" {
+		species, err := getSpecies(ctx, mon)
+		if err != nil {
+			return err
+		}
+		report.Species = describeSpecies(species)
+	}
+
+	if OUTPUT_JSON {
+		return printJSON(report)
+	}
+	localizeReport(ctx, mon, &report)
+	printInspectReport(report)
+	if flags.has("sprite") {
+		return showSprite(ctx, mon, flags)
+	}
+	return nil
+}
+
+// levelUpMoves lists the moves mon learns by level in versionGroup, sorted by
+// level. With no version group given it uses the newest one in the data.
+func levelUpMoves(mon pokemonEntry, versionGroup string) (string, []inspectMove) {
+	if versionGroup == "" {
+		newest := 0
+		for _, move := range mon.Moves {
+			for _, detail := range move.VersionGroupDetails {
+				id := resourceID(detail.VersionGroup.URL)
+				if detail.MoveLearnMethod.Name == "level-up" && id > newest {
+					newest = id
+					versionGroup = detail.VersionGroup.Name
+				}
+			}
+		}
+	}
+	var moves []inspectMove
+	for _, move := range mon.Moves {
+		for _, detail := range move.VersionGroupDetails {
+			if detail.MoveLearnMethod.Name == "level-up" && detail.VersionGroup.Name == versionGroup {
+				moves = append(moves, inspectMove{Name: move.Move.Name, Level: detail.LevelLearnedAt})
+			}
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		if moves[i].Level != moves[j].Level {
+			return moves[i].Level < moves[j].Level
+		}
+		return moves[i].Name < moves[j].Name
+	})
+	return versionGroup, moves
+}
+
+func getSpecies(ctx context.Context, mon pokemonEntry) (pokemonSpecies, error) {
+	species, exists := SPECIES[mon.Species.Name]
+	if exists {
+		return species, nil
+	}
+	err := API.GetJSON(ctx, mon.Species.URL, &species)
+	if err != nil {
+		return species, err
+	}
+	SPECIES[mon.Species.Name] = species
+	return species, nil
+}
+
+// describeSpecies picks the genus and the most recent flavor text entry in
+// LANGUAGE, or in English when there are none in it.
+func describeSpecies(species pokemonSpecies) *inspectSpecies {
+	desc := new(inspectSpecies)
+	for _, lang := range []string{"en", LANGUAGE} {
+		for _, genus := range species.Genera {
+			if genus.Language.Name == lang {
+				desc.Genus = genus.Genus
+			}
+		}
+	}
+	for _, lang := range []string{"en", LANGUAGE} {
+		for _, entry := range species.FlavorTextEntries {
+			if entry.Language.Name == lang {
+				desc.FlavorText = cleanFlavorText(entry.FlavorText)
+				desc.Version = entry.Version.Name
+			}
+		}
+	}
+	return desc
+}
+
+// localizeReport puts the names in report into LANGUAGE. The JSON output
+// keeps the API's names, which scripts can rely on.
+func localizeReport(ctx context.Context, mon pokemonEntry, report *inspectReport) {
+	if LANGUAGE == "" {
+		return
+	}
+	species, err := getSpecies(ctx, mon)
+	if err == nil {
+		rememberLocalNames(species.Names, mon.Name)
+		report.Name = pickName(species.Names, report.Name)
+	}
+	report.Types = localNames(ctx, "type", report.Types)
+	var abilities []string
+	for _, ability := range report.Abilities {
+		abilities = append(abilities, ability.Name)
+	}
+	for i, name := range localNames(ctx, "ability", abilities) {
+		report.Abilities[i].Name = name
+	}
+	var moves []string
+	for _, move := range report.Moves {
+		moves = append(moves, move.Name)
+	}
+	for i, name := range localNames(ctx, "move", moves) {
+		report.Moves[i].Name = name
+	}
+}
+
+// cleanFlavorText undoes the line and page breaks the games' text boxes
+// put in flavor text.
+func cleanFlavorText(text string) string {
+	text = strings.NewReplacer("\u00ad\n", "", "\f", " ", "\n", " ").Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func printInspectReport(report inspectReport) {
+	fmt.Printf("Name: %s\n", report.Name)
+	if report.Stats != nil {
+		fmt.Printf("height: %v\n", report.Height)
+		fmt.Printf("weight: %v\n", report.Weight)
+		fmt.Printf("stats:\n")
+		for _, stat := range baseStats {
+			value, exists := report.Stats[stat]
+			if exists {
+				fmt.Printf("  -%s: %v\n", stat, value)
+			}
+		}
+		fmt.Printf("types:\n")
+		for _, pType := range report.Types {
+			fmt.Printf("  - %s\n", pType)
+		}
+	}
+	if report.Abilities != nil {
+		fmt.Printf("abilities:\n")
+		for _, ability := range report.Abilities {
+			if ability.Hidden {
+				fmt.Printf("  - %s (hidden)\n", ability.Name)
+			} else {
+				fmt.Printf("  - %s\n", ability.Name)
+			}
+		}
+	}
+	if report.VersionGroup != "" {
+		fmt.Printf("level-up moves (%s):\n", report.VersionGroup)
+		for _, move := range report.Moves {
+			fmt.Printf("  - Lv %2d %s\n", move.Level, move.Name)
+		}
+	}
+	if report.Species != nil {
+		fmt.Printf("species:\n")
+		fmt.Printf("  genus: %s\n", report.Species.Genus)
+		if report.Species.FlavorText != "" {
+			fmt.Printf("  %s (%s)\n", report.Species.FlavorText, report.Species.Version)
+		}
+	}
+}
+
+// resourceID returns the numeric id at the end of a PokeAPI resource URL
+// such as https://pokeapi.co/api/v2/version-group/20/, or 0 if there is none.
+func resourceID(url string) int {
+	parts := strings.Split(strings.TrimSuffix(url, "/"), "/")
+	id, _ := strconv.Atoi(parts[len(parts)-1])
+	return id
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is where code that waits gets the time from, so tests can make
+// waiting instant.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the part of time.Ticker a Clock hands out.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+// RealClock is the system clock.
+var RealClock Clock = realClock{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (ticker realTicker) C() <-chan time.Time {
+	return ticker.Ticker.C
+}
+
+// FakeClock is a Clock for tests whose time only moves when Advance is
+// called. Its tickers deliver every tick they are due, waiting for each to
+// be received, so when Advance returns whoever was ticked has started on
+// it.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at     time.Time
+	period time.Duration // zero for After
+	c      chan time.Time
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	timer := &fakeTimer{at: clock.now.Add(d), c: make(chan time.Time, 1), stop: make(chan struct{})}
+	if d <= 0 {
+		timer.c <- clock.now
+		return timer.c
+	}
+	clock.timers = append(clock.timers, timer)
+	return timer.c
+}
+
+func (clock *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	timer := &fakeTimer{at: clock.now.Add(d), period: d, c: make(chan time.Time), stop: make(chan struct{})}
+	clock.timers = append(clock.timers, timer)
+	return timer
+}
+
+func (timer *fakeTimer) C() <-chan time.Time {
+	return timer.c
+}
+
+func (timer *fakeTimer) Stop() {
+	timer.once.Do(func() {
+		close(timer.stop)
+	})
+}
+
+// Advance moves the clock forward by d, firing the timers that come due
+// in order.
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	end := clock.now.Add(d)
+	clock.mu.Unlock()
+	for {
+		clock.mu.Lock()
+		next := -1
+		for i, timer := range clock.timers {
+			if !timer.at.After(end) && (next < 0 || timer.at.Before(clock.timers[next].at)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			clock.now = end
+			clock.mu.Unlock()
+			return
+		}
+		timer := clock.timers[next]
+		clock.now = timer.at
+		if timer.period > 0 {
+			timer.at = timer.at.Add(timer.period)
+		} else {
+			clock.timers = append(clock.timers[:next], clock.timers[next+1:]...)
+		}
+		now := clock.now
+		clock.mu.Unlock()
+
+		if timer.period == 0 {
+			timer.c <- now
+			continue
+		}
+		select {
+		case timer.c <- now:
+		case <-timer.stop:
+			clock.remove(timer)
+		}
+	}
+}
+
+func (clock *FakeClock) remove(timer *fakeTimer) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	for i, t := range clock.timers {
+		if t == timer {
+			clock.timers = append(clock.timers[:i], clock.timers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for resources PokeAPI answers with a 404.
+var ErrNotFound = errors.New("not found")
+
+// StatusError is returned for any other response that isn't a success.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is how long the server asked us to wait, if it did.
+	RetryAfter time.Duration
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("GET %s failed with status %d", err.URL, err.StatusCode)
+}
+
+// Client fetches resources from PokeAPI. Every request is bound to the
+// caller's context and, when RequestTimeout is set, each attempt gets its
+// own deadline. Failed GETs that might succeed later (network errors,
+// timeouts, 429 and 5xx) are retried with jittered exponential backoff,
+// or after the server's Retry-After when it gives one.
+//
+// To stay within PokeAPI's fair use policy every attempt also takes a
+// token from Limiter and one of a limited number of connection slots. One
+// Client is meant to be shared by everything that talks to the API.
+type Client struct {
+	HTTPClient     *http.Client
+	RequestTimeout time.Duration
+	// MaxAttempts caps how many times one GET is tried, MaxElapsed how
+	// long we keep retrying it. Zero MaxElapsed means no limit.
+	MaxAttempts int
+	MaxElapsed  time.Duration
+	// BaseDelay is the wait before the first retry, doubling after each
+	// attempt up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Clock     Clock
+	// Limiter paces requests; nil means no limit.
+	Limiter *RateLimiter
+	// Logf, when set, is told about every retry and every wait for the
+	// rate limiter.
+	Logf func(format string, args ...any)
+
+	connsMu sync.Mutex
+	conns   chan struct{}
+}
+
+func NewClient(requestTimeout time.Duration) *Client {
+	return &Client{
+		HTTPClient:     &http.Client{},
+		RequestTimeout: requestTimeout,
+		MaxAttempts:    4,
+		MaxElapsed:     30 * time.Second,
+		BaseDelay:      500 * time.Millisecond,
+		MaxDelay:       8 * time.Second,
+		Clock:          RealClock,
+		Limiter:        NewRateLimiter(5, 10, RealClock),
+		conns:          make(chan struct{}, 4),
+	}
+}
+
+// SetMaxConns caps how many requests may be in flight at once, zero for
+// no cap. Requests already waiting for a slot keep the old cap.
+func (client *Client) SetMaxConns(n int) {
+	client.connsMu.Lock()
+	defer client.connsMu.Unlock()
+	if n <= 0 {
+		client.conns = nil
+	} else {
+		client.conns = make(chan struct{}, n)
+	}
+}
+
+func (client *Client) MaxConns() int {
+	client.connsMu.Lock()
+	defer client.connsMu.Unlock()
+	return cap(client.conns)
+}
+
+// acquire waits for a connection slot and a rate limiter token. It returns
+// a function giving the slot back and how long it had to wait.
+func (client *Client) acquire(ctx context.Context) (func(), time.Duration, error) {
+	client.connsMu.Lock()
+	conns := client.conns
+	client.connsMu.Unlock()
+
+	var waited time.Duration
+	release := func() {}
+	if conns != nil {
+		select {
+		case conns <- struct{}{}:
+		default:
+			start := client.Clock.Now()
+			select {
+			case conns <- struct{}{}:
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			}
+			waited += client.Clock.Now().Sub(start)
+		}
+		release = func() { <-conns }
+	}
+	if client.Limiter != nil {
+		wait, err := client.Limiter.Wait(ctx)
+		if err != nil {
+			release()
+			return nil, 0, err
+		}
+		waited += wait
+	}
+	return release, waited, nil
+}
+
+func (client *Client) logf(format string, args ...any) {
+	if client.Logf != nil {
+		client.Logf(format, args...)
+	}
+}
+
+// Get returns the body of url.
+func (client *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	start := client.Clock.Now()
+	for attempt := 1; ; attempt++ {
+		body, err := client.attempt(ctx, url)
+		if err == nil || !retryable(err) || ctx.Err() != nil || attempt >= client.MaxAttempts {
+			return body, err
+		}
+		delay := client.backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			delay = statusErr.RetryAfter
+		}
+		if client.MaxElapsed > 0 && client.Clock.Now().Sub(start)+delay > client.MaxElapsed {
+			return nil, err
+		}
+		client.logf("%v; retrying in %v (attempt %d of %d)", err, delay.Round(time.Millisecond), attempt+1, client.MaxAttempts)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-client.Clock.After(delay):
+		}
+	}
+}
+
+// backoff returns how long to wait after the given failed attempt: half
+// the exponential delay plus a random share of the other half, so clients
+// that failed together don't retry together.
+func (client *Client) backoff(attempt int) time.Duration {
+	delay := client.BaseDelay << (attempt - 1)
+	if delay > client.MaxDelay || delay <= 0 {
+		delay = client.MaxDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func retryable(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+func (client *Client) attempt(ctx context.Context, url string) ([]byte, error) {
+	release, waited, err := client.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if waited > 0 {
+		client.logf("waited %v for the rate limiter before GET %s", waited.Round(time.Millisecond), url)
+	}
+
+	reqCtx := ctx
+	if client.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, client.RequestTimeout)
+		defer cancel()
+	}
+	body, err := client.get(reqCtx, url)
+	if err != nil && ctx.Err() == nil && reqCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("request to %s timed out after %v", url, client.RequestTimeout)
+	}
+	return body, err
+}
+
+func (client *Client) get(ctx context.Context, url string) ([]byte, error) {
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	resp, httpErr := client.HTTPClient.Do(req)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	defer resp.Body.Close()
+	body, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode > 299 {
+		statusErr := &StatusError{URL: url, StatusCode: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			statusErr.RetryAfter = client.retryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, statusErr
+	}
+	return body, nil
+}
+
+// retryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func (client *Client) retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(header)
+	if err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	when, err := http.ParseTime(header)
+	if err == nil {
+		return max(when.Sub(client.Clock.Now()), 0)
+	}
+	return 0
+}
+
+// GetJSON fetches url and decodes the body into v.
+func (client *Client) GetJSON(ctx context.Context, url string, v any) error {
+	body, err := client.Get(ctx, url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
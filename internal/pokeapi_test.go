@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientGetJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pokemon/pikachu":
+			w.Write([]byte(`{"name":"pikachu","base_experience":112}`))
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(time.Second)
+	client.Clock = &sleepRecorder{}
+
+	var mon struct {
+		Name           string `json:"name"`
+		BaseExperience int    `json:"base_experience"`
+	}
+	err := client.GetJSON(context.Background(), server.URL+"/pokemon/pikachu", &mon)
+	if err != nil || mon.Name != "pikachu" || mon.BaseExperience != 112 {
+		t.Errorf("expected pikachu, got %+v, %v", mon, err)
+	}
+
+	_, err = client.Get(context.Background(), server.URL+"/pokemon/missingno")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_, err = client.Get(context.Background(), server.URL+"/broken")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 500 {
+		t.Errorf("expected a 500 StatusError, got %v", err)
+	}
+}
+
+func TestClientTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(10 * time.Millisecond)
+	client.MaxAttempts = 1
+	_, err := client.Get(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Errorf("expected a request timeout, got %v", err)
+	}
+
+	client = NewClient(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err = client.Get(ctx, server.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled, got %v", err)
+	}
+}
+
+// sleepRecorder is a Clock whose After fires at once, moving its time
+// forward and remembering how long it was asked to wait.
+type sleepRecorder struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (clock *sleepRecorder) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *sleepRecorder) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+	clock.sleeps = append(clock.sleeps, d)
+	fired := make(chan time.Time, 1)
+	fired <- clock.now
+	return fired
+}
+
+// NewTicker returns a ticker that never fires; the client doesn't tick.
+func (clock *sleepRecorder) NewTicker(d time.Duration) Ticker {
+	return NewFakeClock(clock.Now()).NewTicker(d)
+}
+
+// flakyServer answers with the given statuses in turn, then 200 OK.
+func flakyServer(statuses []int, header http.Header) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[requests-1])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	return server, &requests
+}
+
+func TestClientRetries(t *testing.T) {
+	cases := []struct {
+		name      string
+		statuses  []int
+		header    http.Header
+		expectErr bool
+		requests  int
+		sleeps    []time.Duration
+	}{
+		{
+			name:     "retries server errors",
+			statuses: []int{503, 502},
+			requests: 3,
+		},
+		{
+			name:     "honors Retry-After",
+			statuses: []int{429},
+			header:   http.Header{"Retry-After": {"7"}},
+			requests: 2,
+			sleeps:   []time.Duration{7 * time.Second},
+		},
+		{
+			name:      "does not retry not found",
+			statuses:  []int{404},
+			expectErr: true,
+			requests:  1,
+		},
+		{
+			name:      "gives up after max attempts",
+			statuses:  []int{500, 500, 500, 500, 500},
+			expectErr: true,
+			requests:  4,
+		},
+		{
+			name:      "gives up when Retry-After is past max elapsed",
+			statuses:  []int{503},
+			header:    http.Header{"Retry-After": {"60"}},
+			expectErr: true,
+			requests:  1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, requests := flakyServer(c.statuses, c.header)
+			defer server.Close()
+			clock := &sleepRecorder{now: time.Unix(0, 0)}
+			client := NewClient(time.Second)
+			client.Clock = clock
+			var retries int
+			client.Logf = func(format string, args ...any) { retries++ }
+
+			body, err := client.Get(context.Background(), server.URL)
+			if c.expectErr && err == nil {
+				t.Errorf("expected an error")
+			}
+			if !c.expectErr && (err != nil || string(body) != "ok") {
+				t.Errorf("expected ok, got %q, %v", body, err)
+			}
+			if *requests != c.requests {
+				t.Errorf("expected %v requests, got %v", c.requests, *requests)
+			}
+			if retries != len(clock.sleeps) {
+				t.Errorf("expected every retry to be logged, got %v logs for %v sleeps", retries, len(clock.sleeps))
+			}
+			if c.sleeps != nil && (len(clock.sleeps) != len(c.sleeps) || clock.sleeps[0] != c.sleeps[0]) {
+				t.Errorf("expected sleeps %v, got %v", c.sleeps, clock.sleeps)
+			}
+		})
+	}
+}
+
+func TestClientBackoff(t *testing.T) {
+	client := NewClient(time.Second)
+	for attempt := 1; attempt <= 8; attempt++ {
+		full := min(client.BaseDelay<<(attempt-1), client.MaxDelay)
+		delay := client.backoff(attempt)
+		if delay < full/2 || delay > full {
+			t.Errorf("attempt %v: expected a delay between %v and %v, got %v", attempt, full/2, full, delay)
+		}
+	}
+}
@@ -1,53 +1,247 @@
 package internal
 import (
+	"context"
+	"errors"
 	"time"
 	"sync"
 )
 
-type cacheEntry struct {
+type cacheEntry[V any] struct {
 	createdAt time.Time
-	val []byte
+	// a value is fresh until expiresAt and served stale until staleUntil
+	expiresAt time.Time
+	staleUntil time.Time
+	val V
+	missing bool // a negative entry, recorded with AddMissing
 }
 
-type Cache struct {
-	Map map[string]cacheEntry
+// LookupResult says what Get found for a key.
+type LookupResult int
+
+const (
+	// Miss means the cache knows nothing about the key.
+	Miss LookupResult = iota
+	// Hit means the value is cached.
+	Hit
+	// KnownMissing means the key was recently found not to exist.
+	KnownMissing
+	// Stale means the value has expired but is still within its stale
+	// window, so it can be used while a fresh one is fetched.
+	Stale
+)
+
+// Cache holds values of type V by key. Values added with Add last for the
+// interval the cache was created with; AddWithTTL gives each value its own
+// lifetime and stale window. It can also remember keys that don't exist,
+// for a separate and usually shorter time.
+type Cache[K comparable, V any] struct {
+	Map map[K]cacheEntry[V]
 	Mu sync.Mutex
+	interval time.Duration
+	missingTTL time.Duration
+	refreshing map[K]bool
+	clock Clock
+	reaper *reaper
+}
+
+type cacheConfig struct {
+	missingTTL time.Duration
+	clock Clock
+}
+
+// CacheOption changes how NewTypedCache sets up a cache.
+type CacheOption func(*cacheConfig)
+
+// WithMissingTTL sets how long AddMissing entries last. By default they
+// last as long as values do.
+func WithMissingTTL(ttl time.Duration) CacheOption {
+	return func(config *cacheConfig) {
+		config.missingTTL = ttl
+	}
+}
+
+// WithClock makes the cache tell the time, and reap, by clock instead of
+// the system clock.
+func WithClock(clock Clock) CacheOption {
+	return func(config *cacheConfig) {
+		config.clock = clock
+	}
+}
+
+// ByteCache is the original byte-oriented cache, for raw HTTP bodies.
+type ByteCache = Cache[string, []byte]
+
+func NewCache(interval time.Duration) *ByteCache {
+	return NewTypedCache[string, []byte](interval)
+}
+
+// NewTypedCache makes a cache for decoded values, so callers don't have to
+// turn them back into bytes. The interval is the lifetime Add gives values
+// and how often expired entries are reaped.
+func NewTypedCache[K comparable, V any](interval time.Duration, opts ...CacheOption) *Cache[K, V] {
+	config := newCacheConfig(interval, opts)
+	cache := newCache[K, V](interval, config)
+	cache.reaper = startReaper(config.clock, min(interval, config.missingTTL), cache.reapLoop)
+	return cache
+}
+
+func newCacheConfig(interval time.Duration, opts []CacheOption) cacheConfig {
+	config := cacheConfig{missingTTL: interval, clock: RealClock}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
 }
 
-func NewCache(interval time.Duration) Cache {
-	cache := new(Cache)
-	cache.Map = make(map[string]cacheEntry)
-	
-	ticker := time.NewTicker(interval)
+// newCache makes a cache without a reaper, leaving reaping to the caller.
+func newCache[K comparable, V any](interval time.Duration, config cacheConfig) *Cache[K, V] {
+	cache := new(Cache[K, V])
+	cache.Map = make(map[K]cacheEntry[V])
+	cache.interval = interval
+	cache.missingTTL = config.missingTTL
+	cache.refreshing = make(map[K]bool)
+	cache.clock = config.clock
+	return cache
+}
+
+// reaper calls reap on every tick until it is stopped.
+type reaper struct {
+	done chan struct{}
+	stopped chan struct{}
+	stopOnce sync.Once
+}
+
+func startReaper(clock Clock, every time.Duration, reap func()) *reaper {
+	r := &reaper{done: make(chan struct{}), stopped: make(chan struct{})}
+	ticker := clock.NewTicker(every)
 	go func() {
-		for range ticker.C {
-			cache.reapLoop(interval)
+		defer close(r.stopped)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				reap()
+			case <-r.done:
+				return
+			}
 		}
 	}()
-	
-	return *cache
+	return r
+}
+
+func (r *reaper) stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+	<-r.stopped
 }
 
-func (cache Cache) Add(key string, val []byte) {
+func (cache *Cache[K, V]) Add(key K, val V) {
+	cache.AddWithTTL(key, val, cache.interval, 0)
+}
+
+// AddWithTTL caches val for ttl, after which Get reports it Stale for up
+// to staleWindow more before it is dropped.
+func (cache *Cache[K, V]) AddWithTTL(key K, val V, ttl time.Duration, staleWindow time.Duration) {
+	now := cache.clock.Now()
 	cache.Mu.Lock()
-	newEntry := cacheEntry{createdAt:time.Now(),val:val}
+	newEntry := cacheEntry[V]{createdAt:now,expiresAt:now.Add(ttl),staleUntil:now.Add(ttl+staleWindow),val:val}
 	cache.Map[key] = newEntry
 	cache.Mu.Unlock()
 }
 
-func (cache Cache) Get(key string) ([]byte, bool) {
+// AddMissing records that key doesn't exist, replacing any value it had.
+func (cache *Cache[K, V]) AddMissing(key K) {
+	now := cache.clock.Now()
+	cache.Mu.Lock()
+	cache.Map[key] = cacheEntry[V]{createdAt:now,expiresAt:now.Add(cache.missingTTL),staleUntil:now.Add(cache.missingTTL),missing:true}
+	cache.Mu.Unlock()
+}
+
+// Get returns the value for key and whether it was a hit, a miss, known
+// to be missing or stale. Only hits and stale results have a value.
+func (cache *Cache[K, V]) Get(key K) (V, LookupResult) {
 	cache.Mu.Lock() // This seems safe? I don't think I need this.
 	value, exists := cache.Map[key]
 	cache.Mu.Unlock()
-	return value.val, exists
+	// the reaper may not have got to expired entries yet
+	now := cache.clock.Now()
+	if !exists || now.After(value.staleUntil) {
+		var zero V
+		return zero, Miss
+	}
+	if value.missing {
+		return value.val, KnownMissing
+	}
+	if now.After(value.expiresAt) {
+		return value.val, Stale
+	}
+	return value.val, Hit
+}
+
+// Fetch returns the value for key, calling load on a miss and caching what
+// it returns with AddWithTTL. A stale value is returned as it is while load
+// runs again in the background to replace it. A load failing with
+// ErrNotFound is cached as missing and reported as KnownMissing.
+func (cache *Cache[K, V]) Fetch(ctx context.Context, key K, ttl time.Duration, staleWindow time.Duration, load func(ctx context.Context) (V, error)) (V, LookupResult, error) {
+	val, result := cache.Get(key)
+	switch result {
+	case Hit, KnownMissing:
+		return val, result, nil
+	case Stale:
+		cache.refresh(key, ttl, staleWindow, load)
+		return val, Stale, nil
+	}
+	val, err := load(ctx)
+	if errors.Is(err, ErrNotFound) {
+		cache.AddMissing(key)
+		return val, KnownMissing, nil
+	}
+	if err != nil {
+		return val, Miss, err
+	}
+	cache.AddWithTTL(key, val, ttl, staleWindow)
+	return val, Hit, nil
+}
+
+// refresh reloads a stale key in the background, one load per key at a
+// time. The caller's context may end long before the load does, so it gets
+// its own. If the load fails the stale value is kept out its window.
+func (cache *Cache[K, V]) refresh(key K, ttl time.Duration, staleWindow time.Duration, load func(ctx context.Context) (V, error)) {
+	cache.Mu.Lock()
+	if cache.refreshing[key] {
+		cache.Mu.Unlock()
+		return
+	}
+	cache.refreshing[key] = true
+	cache.Mu.Unlock()
+
+	go func() {
+		val, err := load(context.Background())
+		if errors.Is(err, ErrNotFound) {
+			cache.AddMissing(key)
+		} else if err == nil {
+			cache.AddWithTTL(key, val, ttl, staleWindow)
+		}
+		cache.Mu.Lock()
+		delete(cache.refreshing, key)
+		cache.Mu.Unlock()
+	}()
 }
 
-func (cache Cache) reapLoop(interval time.Duration) {
+// Stop ends the reaper goroutine, waiting for a reap in progress to
+// finish. Entries already in the cache are no longer reaped, though Get
+// still treats expired ones as misses.
+func (cache *Cache[K, V]) Stop() {
+	cache.reaper.stop()
+}
+
+func (cache *Cache[K, V]) reapLoop() {
 	cache.Mu.Lock()
-	for key,_ := range cache.Map {
-		if cache.Map[key].createdAt.Before((time.Now().Add(-interval))) {
+	for key,entry := range cache.Map {
+		if entry.staleUntil.Before(cache.clock.Now()) {
 			delete(cache.Map,key)
 		}
 	}
 	cache.Mu.Unlock()
-}
\ No newline at end of file
+}
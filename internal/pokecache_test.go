@@ -1,5 +1,7 @@
 package internal
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -25,8 +27,8 @@ func TestAddGet(t *testing.T) {
 		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
 			cache := NewCache(interval)
 			cache.Add(c.key, c.val)
-			val, ok := cache.Get(c.key)
-			if !ok {
+			val, result := cache.Get(c.key)
+			if result != Hit {
 				t.Errorf("expected to find key")
 				return
 			}
@@ -58,8 +60,8 @@ func TestAddGetRemove(t *testing.T) {
 		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
 
 			cache.Add(c.key, c.val)
-			val, ok := cache.Get(c.key)
-			if !ok {
+			val, result := cache.Get(c.key)
+			if result != Hit {
 				t.Errorf("expected to find key")
 				return
 			}
@@ -71,30 +73,227 @@ func TestAddGetRemove(t *testing.T) {
 	}
 
 	delete(cache.Map, "https://example.com/path")
-	_, ok := cache.Get("https://example.com/path")
-	if ok {
+	_, result := cache.Get("https://example.com/path")
+	if result != Miss {
 		t.Errorf("did not expect to find key")
 		return
 	}
 }
 
+// inMap reports whether key is still in the cache's map, reaped or not.
+// It stops the reaper first, so a reap the clock just set off is over.
+func inMap[K comparable, V any](cache *Cache[K, V], key K) bool {
+	cache.Stop()
+	cache.Mu.Lock()
+	defer cache.Mu.Unlock()
+	_, ok := cache.Map[key]
+	return ok
+}
+
 func TestReapLoop(t *testing.T) {
-	const baseTime = 5 * time.Millisecond
-	const waitTime = baseTime + 5*time.Millisecond
-	cache := NewCache(baseTime)
+	const baseTime = 5 * time.Second
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache := NewTypedCache[string, []byte](baseTime, WithClock(clock))
 	cache.Add("https://example.com", []byte("testdata"))
 
-	_, ok := cache.Get("https://example.com")
-	if !ok {
+	_, result := cache.Get("https://example.com")
+	if result != Hit {
 		t.Errorf("expected to find key")
 		return
 	}
 
-	time.Sleep(waitTime)
+	clock.Advance(baseTime - time.Millisecond)
+	if _, result = cache.Get("https://example.com"); result != Hit {
+		t.Errorf("expected to find key before it expires")
+		return
+	}
 
-	_, ok = cache.Get("https://example.com")
-	if ok {
+	clock.Advance(baseTime + time.Millisecond)
+	_, result = cache.Get("https://example.com")
+	if result != Miss {
 		t.Errorf("expected to not find key")
 		return
 	}
-}
\ No newline at end of file
+	if inMap(cache, "https://example.com") {
+		t.Errorf("expected the reaper to remove the key")
+	}
+}
+
+func TestStop(t *testing.T) {
+	const baseTime = 5 * time.Second
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache := NewTypedCache[string, []byte](baseTime, WithClock(clock))
+	cache.Add("https://example.com", []byte("testdata"))
+	cache.Stop()
+	cache.Stop()
+
+	clock.Advance(2 * baseTime)
+
+	cache.Mu.Lock()
+	_, ok := cache.Map["https://example.com"]
+	cache.Mu.Unlock()
+	if !ok {
+		t.Errorf("expected the entry not to be reaped after stopping the reaper")
+		return
+	}
+}
+
+func TestTypedCache(t *testing.T) {
+	type area struct {
+		Name    string
+		Pokemon []string
+	}
+	cache := NewTypedCache[string, area](5 * time.Second)
+	cache.Add("canalave-city-area", area{Name: "canalave-city-area", Pokemon: []string{"tentacool", "wingull"}})
+
+	val, result := cache.Get("canalave-city-area")
+	if result != Hit {
+		t.Errorf("expected to find key")
+		return
+	}
+	if val.Name != "canalave-city-area" || len(val.Pokemon) != 2 {
+		t.Errorf("expected to find value, got %+v", val)
+		return
+	}
+
+	pages := NewTypedCache[int, []string](5 * time.Second)
+	pages.Add(20, []string{"eterna-forest-area"})
+	if _, result := pages.Get(40); result != Miss {
+		t.Errorf("did not expect to find key")
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	cache := NewTypedCache[string, []byte](5*time.Second, WithMissingTTL(time.Second))
+	cache.Add("https://example.com", []byte("testdata"))
+	cache.AddMissing("https://example.com/typo")
+
+	cases := []struct {
+		key      string
+		expected LookupResult
+	}{
+		{key: "https://example.com", expected: Hit},
+		{key: "https://example.com/typo", expected: KnownMissing},
+		{key: "https://example.com/other", expected: Miss},
+	}
+	for _, c := range cases {
+		_, result := cache.Get(c.key)
+		if result != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.key, c.expected, result)
+		}
+	}
+
+	// a negative entry has no value
+	if val, result := cache.Get("https://example.com/typo"); result != KnownMissing || val != nil {
+		t.Errorf("expected Get to report the key as known missing, got %q, %v", val, result)
+	}
+
+	// and a real value replaces it
+	cache.Add("https://example.com/typo", []byte("fixed"))
+	if val, result := cache.Get("https://example.com/typo"); result != Hit || string(val) != "fixed" {
+		t.Errorf("expected the value to replace the missing entry, got %v", result)
+	}
+}
+
+func TestReapMissing(t *testing.T) {
+	const baseTime = 5 * time.Second
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache := NewTypedCache[string, []byte](time.Minute, WithMissingTTL(baseTime), WithClock(clock))
+	cache.Add("https://example.com", []byte("testdata"))
+	cache.AddMissing("https://example.com/typo")
+
+	clock.Advance(2 * baseTime)
+
+	if _, result := cache.Get("https://example.com/typo"); result != Miss {
+		t.Errorf("expected the missing entry to expire, got %v", result)
+	}
+	if _, result := cache.Get("https://example.com"); result != Hit {
+		t.Errorf("expected the value to outlive the missing entry")
+	}
+	if inMap(cache, "https://example.com/typo") {
+		t.Errorf("expected the reaper to remove the missing entry")
+	}
+}
+
+func TestAddWithTTL(t *testing.T) {
+	const baseTime = 5 * time.Second
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache := NewTypedCache[string, string](baseTime, WithClock(clock))
+	cache.AddWithTTL("pikachu", "static", time.Hour, 0)
+	cache.AddWithTTL("route-1", "stale soon", baseTime, time.Minute)
+	cache.Add("default", "short lived")
+
+	clock.Advance(4 * baseTime)
+
+	if _, result := cache.Get("pikachu"); result != Hit {
+		t.Errorf("expected the long-lived value to outlive the interval, got %v", result)
+	}
+	val, result := cache.Get("route-1")
+	if result != Stale || val != "stale soon" {
+		t.Errorf("expected the stale value, got %q, %v", val, result)
+	}
+	if _, result := cache.Get("default"); result != Miss {
+		t.Errorf("expected Add to use the interval, got %v", result)
+	}
+
+	// past its stale window a value is gone for good
+	clock.Advance(time.Minute)
+	if _, result := cache.Get("route-1"); result != Miss {
+		t.Errorf("expected the stale value to expire, got %v", result)
+	}
+	if inMap(cache, "route-1") || !inMap(cache, "pikachu") {
+		t.Errorf("expected the reaper to remove only the expired value")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	const baseTime = 5 * time.Second
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache := NewTypedCache[string, string](time.Minute, WithClock(clock))
+	ctx := context.Background()
+	loads := make(chan string, 10)
+	load := func(val string, err error) func(context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			loads <- val
+			return val, err
+		}
+	}
+
+	val, result, err := cache.Fetch(ctx, "route-1", baseTime, time.Minute, load("v1", nil))
+	if val != "v1" || result != Hit || err != nil {
+		t.Errorf("expected a load on a miss, got %q, %v, %v", val, result, err)
+	}
+	val, _, _ = cache.Fetch(ctx, "route-1", baseTime, time.Minute, load("unused", nil))
+	if val != "v1" || len(loads) != 1 {
+		t.Errorf("expected a fresh value without loading, got %q after %v loads", val, len(loads))
+	}
+
+	clock.Advance(2 * baseTime)
+	val, result, _ = cache.Fetch(ctx, "route-1", time.Minute, time.Minute, load("v2", nil))
+	if val != "v1" || result != Stale {
+		t.Errorf("expected the stale value, got %q, %v", val, result)
+	}
+	<-loads
+	<-loads
+	for range 100 {
+		if val, result := cache.Get("route-1"); result == Hit && val == "v2" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if val, result := cache.Get("route-1"); result != Hit || val != "v2" {
+		t.Errorf("expected the background refresh to replace the value, got %q, %v", val, result)
+	}
+
+	_, result, err = cache.Fetch(ctx, "missingno", time.Minute, 0, load("", ErrNotFound))
+	if result != KnownMissing || err != nil {
+		t.Errorf("expected a not found load to be cached as missing, got %v, %v", result, err)
+	}
+	_, result, err = cache.Fetch(ctx, "broken", time.Minute, 0, load("", errors.New("boom")))
+	if result != Miss || err == nil {
+		t.Errorf("expected the load error, got %v, %v", result, err)
+	}
+	if _, result := cache.Get("broken"); result != Miss {
+		t.Errorf("did not expect a failed load to be cached, got %v", result)
+	}
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket. It holds up to burst tokens, refilled at
+// rate tokens per second, and every request takes one. Callers that find
+// the bucket empty reserve a future token and wait for it, so waiters are
+// served in order. A rate of zero turns limiting off.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func NewRateLimiter(rate float64, burst int, clock Clock) *RateLimiter {
+	limiter := &RateLimiter{clock: clock, last: clock.Now()}
+	limiter.SetRate(rate, burst)
+	limiter.tokens = limiter.burst
+	return limiter
+}
+
+// SetRate changes the rate and burst, keeping the tokens already saved up
+// as far as the new burst allows.
+func (limiter *RateLimiter) SetRate(rate float64, burst int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.refill()
+	limiter.rate = rate
+	limiter.burst = float64(max(burst, 1))
+	limiter.tokens = min(limiter.tokens, limiter.burst)
+}
+
+func (limiter *RateLimiter) Rate() (float64, int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.rate, int(limiter.burst)
+}
+
+func (limiter *RateLimiter) refill() {
+	now := limiter.clock.Now()
+	if limiter.rate > 0 {
+		limiter.tokens = min(limiter.tokens+now.Sub(limiter.last).Seconds()*limiter.rate, limiter.burst)
+	}
+	limiter.last = now
+}
+
+// Wait takes a token, blocking until one is available or ctx is done, and
+// returns how long it waited.
+func (limiter *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	limiter.mu.Lock()
+	if limiter.rate <= 0 {
+		limiter.mu.Unlock()
+		return 0, nil
+	}
+	limiter.refill()
+	limiter.tokens--
+	var wait time.Duration
+	if limiter.tokens < 0 {
+		wait = time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+	}
+	limiter.mu.Unlock()
+	if wait == 0 {
+		return 0, nil
+	}
+
+	select {
+	case <-limiter.clock.After(wait):
+		return wait, nil
+	case <-ctx.Done():
+		// hand back the token we reserved
+		limiter.mu.Lock()
+		limiter.tokens++
+		limiter.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	clock := &sleepRecorder{now: time.Unix(0, 0)}
+	limiter := NewRateLimiter(2, 2, clock)
+
+	// the burst is free, after that tokens come every half second
+	expected := []time.Duration{0, 0, 500 * time.Millisecond, 500 * time.Millisecond}
+	for i, want := range expected {
+		waited, err := limiter.Wait(context.Background())
+		if err != nil || waited != want {
+			t.Errorf("wait %v: expected %v, got %v, %v", i, want, waited, err)
+		}
+	}
+
+	// idle time refills the bucket, but never past the burst
+	clock.now = clock.now.Add(10 * time.Second)
+	for i := 0; i < 2; i++ {
+		if waited, _ := limiter.Wait(context.Background()); waited != 0 {
+			t.Errorf("expected a saved up token, waited %v", waited)
+		}
+	}
+	if waited, _ := limiter.Wait(context.Background()); waited != 500*time.Millisecond {
+		t.Errorf("expected the burst to be used up, waited %v", waited)
+	}
+}
+
+func TestRateLimiterOff(t *testing.T) {
+	clock := &sleepRecorder{now: time.Unix(0, 0)}
+	limiter := NewRateLimiter(0, 1, clock)
+	for i := 0; i < 10; i++ {
+		if waited, _ := limiter.Wait(context.Background()); waited != 0 {
+			t.Errorf("expected no waiting with limiting off, waited %v", waited)
+		}
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 1, RealClock)
+	limiter.Wait(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := limiter.Wait(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
+
+func TestClientMaxConns(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, most := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		most = max(most, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second)
+	client.Limiter = nil
+	client.SetMaxConns(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Get(context.Background(), server.URL)
+		}()
+	}
+	wg.Wait()
+	if most > 2 {
+		t.Errorf("expected at most 2 requests in flight, saw %v", most)
+	}
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"hash/maphash"
+	"time"
+)
+
+// DefaultShards is how many segments NewShardedCache makes when asked for
+// none.
+const DefaultShards = 16
+
+// ShardedCache is a Cache split into independently locked shards, picked
+// by a hash of the key, for callers doing many lookups at once. Reaping is
+// incremental: each tick reaps the next shard, so no lock is ever held
+// across the whole cache and every shard is still reaped once per
+// interval.
+type ShardedCache[K comparable, V any] struct {
+	shards []*Cache[K, V]
+	seed   maphash.Seed
+	reaper *reaper
+	next   int // the shard the reaper goes to next
+}
+
+// NewShardedCache makes a cache of shards segments, taking the same
+// interval and options as NewTypedCache.
+func NewShardedCache[K comparable, V any](shards int, interval time.Duration, opts ...CacheOption) *ShardedCache[K, V] {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	config := newCacheConfig(interval, opts)
+	cache := &ShardedCache[K, V]{shards: make([]*Cache[K, V], shards), seed: maphash.MakeSeed()}
+	for i := range cache.shards {
+		cache.shards[i] = newCache[K, V](interval, config)
+	}
+	every := max(min(interval, config.missingTTL)/time.Duration(shards), time.Millisecond)
+	cache.reaper = startReaper(config.clock, every, cache.reapNext)
+	return cache
+}
+
+func (cache *ShardedCache[K, V]) shard(key K) *Cache[K, V] {
+	return cache.shards[maphash.Comparable(cache.seed, key)%uint64(len(cache.shards))]
+}
+
+func (cache *ShardedCache[K, V]) Add(key K, val V) {
+	cache.shard(key).Add(key, val)
+}
+
+func (cache *ShardedCache[K, V]) AddWithTTL(key K, val V, ttl time.Duration, staleWindow time.Duration) {
+	cache.shard(key).AddWithTTL(key, val, ttl, staleWindow)
+}
+
+func (cache *ShardedCache[K, V]) AddMissing(key K) {
+	cache.shard(key).AddMissing(key)
+}
+
+func (cache *ShardedCache[K, V]) Get(key K) (V, LookupResult) {
+	return cache.shard(key).Get(key)
+}
+
+func (cache *ShardedCache[K, V]) Fetch(ctx context.Context, key K, ttl time.Duration, staleWindow time.Duration, load func(ctx context.Context) (V, error)) (V, LookupResult, error) {
+	return cache.shard(key).Fetch(ctx, key, ttl, staleWindow, load)
+}
+
+// Len counts the entries in every shard, expired or not.
+func (cache *ShardedCache[K, V]) Len() int {
+	n := 0
+	for _, shard := range cache.shards {
+		shard.Mu.Lock()
+		n += len(shard.Map)
+		shard.Mu.Unlock()
+	}
+	return n
+}
+
+// Stop ends the reaper goroutine, as Cache.Stop does.
+func (cache *ShardedCache[K, V]) Stop() {
+	cache.reaper.stop()
+}
+
+func (cache *ShardedCache[K, V]) reapNext() {
+	shard := cache.shards[cache.next]
+	cache.next = (cache.next + 1) % len(cache.shards)
+	shard.reapLoop()
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+	const baseTime = 5 * time.Second
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache := NewShardedCache[string, int](4, baseTime, WithMissingTTL(time.Second), WithClock(clock))
+	for i := 0; i < 100; i++ {
+		cache.Add(fmt.Sprint("key", i), i)
+	}
+	cache.AddWithTTL("pikachu", 25, time.Hour, 0)
+	cache.AddMissing("missingno")
+
+	for i := 0; i < 100; i++ {
+		if val, result := cache.Get(fmt.Sprint("key", i)); result != Hit || val != i {
+			t.Errorf("expected key%v to be %v, got %v, %v", i, i, val, result)
+		}
+	}
+	if _, result := cache.Get("missingno"); result != KnownMissing {
+		t.Errorf("expected missingno to be known missing, got %v", result)
+	}
+
+	// the reaper visits one shard per tick, so every shard within an interval
+	clock.Advance(baseTime + time.Second)
+	cache.Stop()
+	if n := cache.Len(); n != 1 {
+		t.Errorf("expected only pikachu to be left after reaping, found %v entries", n)
+	}
+	if val, result := cache.Get("pikachu"); result != Hit || val != 25 {
+		t.Errorf("expected pikachu to outlive the reaping, got %v, %v", val, result)
+	}
+}
+
+func TestShardedCacheConcurrent(t *testing.T) {
+	cache := NewShardedCache[int, int](0, time.Minute)
+	defer cache.Stop()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cache.Add(i, i)
+				if val, result := cache.Get(i); result != Hit || val != i {
+					t.Errorf("expected %v, got %v, %v", i, val, result)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := cache.Len(); n != 1000 {
+		t.Errorf("expected 1000 entries, found %v", n)
+	}
+}
+
+// benchCache is what the benchmarks need from both cache designs.
+type benchCache interface {
+	Add(key string, val []byte)
+	Get(key string) ([]byte, LookupResult)
+	Stop()
+}
+
+// benchmarkParallel has every goroutine look up keys, writing one time in
+// ten, while the reaper runs often enough to get in the way.
+func benchmarkParallel(b *testing.B, cache benchCache) {
+	defer cache.Stop()
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%d", i)
+		cache.Add(keys[i], []byte("testdata"))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				cache.Add(key, []byte("testdata"))
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheParallel(b *testing.B) {
+	benchmarkParallel(b, NewTypedCache[string, []byte](10*time.Millisecond))
+}
+
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	benchmarkParallel(b, NewShardedCache[string, []byte](DefaultShards, 10*time.Millisecond))
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"pokedexcli/internal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LANGUAGE is the language names are shown in, or "" for the API's own
+// names.
+var LANGUAGE string
+
+// languages are the languages PokeAPI has names in.
+var languages = []string{"cs", "de", "en", "es", "fr", "it", "ja", "ja-Hrkt", "ko", "pt-BR", "roomaji", "zh-Hans", "zh-Hant"}
+
+type localizedName struct {
+	Language namedResource `json:"language"`
+	Name     string        `json:"name"`
+}
+
+var NAMES_CACHE *internal.Cache[string, []localizedName] // keyed by kind/name
+
+// LOCAL_NAMES maps the names of pokemon in every language we've come
+// across, lowercased, to the API's names, so they can be typed in too.
+var LOCAL_NAMES = make(map[string]string)
+
+func parseLanguage(value string) (string, error) {
+	if value == "off" || value == "" {
+		return "", nil
+	}
+	for _, lang := range languages {
+		if strings.EqualFold(lang, value) {
+			return lang, nil
+		}
+	}
+	return "", fmt.Errorf("unknown language %q, expected off or one of %s", value, strings.Join(languages, ", "))
+}
+
+// pickName picks the name in LANGUAGE from names, falling back to English
+// and then to the API's name.
+func pickName(names []localizedName, apiName string) string {
+	if LANGUAGE == "" {
+		return apiName
+	}
+	english := ""
+	for _, name := range names {
+		switch name.Language.Name {
+		case LANGUAGE:
+			return name.Name
+		case "en":
+			english = name.Name
+		}
+	}
+	if english != "" {
+		return english
+	}
+	return apiName
+}
+
+// localName returns the name of a resource of the given kind, such as
+// "move" or "pokemon-species", in LANGUAGE.
+func localName(ctx context.Context, kind string, apiName string) string {
+	return localNames(ctx, kind, []string{apiName})[0]
+}
+
+// localNames is localName for a list of names, which it looks up a few at
+// a time.
+func localNames(ctx context.Context, kind string, apiNames []string) []string {
+	if LANGUAGE == "" {
+		return apiNames
+	}
+	names := make([][]localizedName, len(apiNames))
+	lookupEach(len(apiNames), func(i int) {
+		names[i] = fetchNames(ctx, kind, apiNames[i])
+	})
+	local := make([]string, len(apiNames))
+	for i, apiName := range apiNames {
+		if kind == "pokemon-species" {
+			rememberLocalNames(names[i], apiName)
+		}
+		local[i] = pickName(names[i], apiName)
+	}
+	return local
+}
+
+// fetchNames fetches the names of a resource. Names are a nicety, so when
+// they can't be had the API's name will do.
+func fetchNames(ctx context.Context, kind string, apiName string) []localizedName {
+	names, lookup, err := loadNames(ctx, kind, apiName)
+	if err != nil {
+		verbosef("could not look up the names of %s %s: %v", kind, apiName, err)
+	}
+	if lookup != internal.Hit && lookup != internal.Stale {
+		return nil
+	}
+	return names
+}
+
+func loadNames(ctx context.Context, kind string, apiName string) ([]localizedName, internal.LookupResult, error) {
+	return NAMES_CACHE.Fetch(ctx, kind+"/"+apiName, POKEMON_TTL, POKEMON_STALE, func(ctx context.Context) ([]localizedName, error) {
+		var resource struct {
+			Names []localizedName `json:"names"`
+		}
+		err := API.GetJSON(ctx, fmt.Sprintf("https://pokeapi.co/api/v2/%s/%s", kind, apiName), &resource)
+		return resource.Names, err
+	})
+}
+
+// NAME_WORKERS is how many names are looked up at once. Any more would
+// just queue up behind the rate limiter.
+const NAME_WORKERS = 8
+
+// lookupEach calls lookup for 0 to n-1, NAME_WORKERS at a time.
+func lookupEach(n int, lookup func(i int)) {
+	workers := make(chan struct{}, NAME_WORKERS)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			lookup(i)
+		}()
+	}
+	wg.Wait()
+}
+
+func rememberLocalNames(names []localizedName, apiName string) {
+	for _, name := range names {
+		LOCAL_NAMES[strings.ToLower(name.Name)] = apiName
+	}
+}
+
+// resolveName turns a pokemon name typed in any language into the API's
+// name. Names may have spaces, as in "M. Mime", so all the words are tried
+// together before the first on its own. Besides the names we've come
+// across, names in other languages are looked up in the localized name
+// index when there is one; it's never fetched from here.
+func resolveName(words []string) string {
+	keys := []string{strings.ToLower(strings.Join(words, " ")), strings.ToLower(words[0])}
+	for _, key := range keys {
+		if apiName, exists := LOCAL_NAMES[key]; exists {
+			return apiName
+		}
+	}
+	if LANGUAGE == "" && isASCII(keys[0]) {
+		return keys[1]
+	}
+	index := loadLocalNameIndex()
+	for _, key := range keys {
+		if apiName, exists := index.Names[key]; exists {
+			return apiName
+		}
+	}
+	if index.Fetched.IsZero() && !isASCII(keys[0]) {
+		fmt.Fprintln(os.Stderr, "Run names to look up pokemon names in every language")
+	}
+	return keys[1]
+}
+
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// localNameIndex maps the names of every species in every language,
+// lowercased, to the API's names. There's no list of them, so building it
+// means fetching every species. The names command does that a piece at a
+// time, keeping it on disk.
+type localNameIndex struct {
+	// Fetched is when the index was last completed
+	Fetched time.Time         `json:"fetched"`
+	Total   int               `json:"total"`
+	Done    map[string]bool   `json:"done"`
+	Names   map[string]string `json:"names"`
+}
+
+var LOCAL_INDEX *localNameIndex
+
+// loadLocalNameIndex returns the localized name index as far as it's been
+// built, reading it from disk the first time.
+func loadLocalNameIndex() *localNameIndex {
+	if LOCAL_INDEX == nil {
+		LOCAL_INDEX = &localNameIndex{}
+		data, err := os.ReadFile(dataPath("local-names.json"))
+		if err == nil {
+			json.Unmarshal(data, LOCAL_INDEX)
+		}
+		if LOCAL_INDEX.Done == nil || LOCAL_INDEX.Names == nil {
+			LOCAL_INDEX.Done = make(map[string]bool)
+			LOCAL_INDEX.Names = make(map[string]string)
+		}
+	}
+	return LOCAL_INDEX
+}
+
+// buildLocalNameIndex fetches the species the localized name index doesn't
+// have yet, or when refresh is set all of them again. On error what was
+// fetched so far is kept for next time.
+func buildLocalNameIndex(ctx context.Context, refresh bool) (*localNameIndex, error) {
+	index := loadLocalNameIndex()
+	if refresh {
+		index.Fetched = time.Time{}
+		index.Done = make(map[string]bool)
+	}
+	var list locationArea
+	err := API.GetJSON(ctx, "https://pokeapi.co/api/v2/pokemon-species/?limit=100000", &list)
+	if err != nil {
+		return index, err
+	}
+	var todo []string
+	for _, obj := range list.Results {
+		if !index.Done[obj.Name] {
+			todo = append(todo, obj.Name)
+		}
+	}
+	index.Total = len(list.Results)
+	var mu sync.Mutex
+	errs := make([]error, len(todo))
+	lookupEach(len(todo), func(i int) {
+		names, _, err := loadNames(ctx, "pokemon-species", todo[i])
+		errs[i] = err
+		if err != nil && !errors.Is(err, internal.ErrNotFound) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, name := range names {
+			index.Names[strings.ToLower(name.Name)] = todo[i]
+		}
+		index.Done[todo[i]] = true
+	})
+	err = errors.Join(errs...)
+	if err == nil || len(index.Done) == index.Total {
+		index.Fetched = time.Now()
+		err = nil
+	}
+	saveLocalNameIndex(index)
+	return index, err
+}
+
+// learnNames builds the localized name index. It takes a request per
+// species, so on a short timeout it may take a few goes.
+func learnNames(ctx context.Context, args []string) error {
+	_, flags := parseArgs(args, "refresh")
+	index := loadLocalNameIndex()
+	if !flags.has("refresh") && !index.Fetched.IsZero() {
+		fmt.Printf("Already know the names of %d species in every language, use --refresh to look them up again\n", len(index.Done))
+		return nil
+	}
+	fmt.Println("Looking up pokemon names in every language...")
+	index, err := buildLocalNameIndex(ctx, flags.has("refresh"))
+	if err != nil {
+		if index.Total == 0 {
+			return err
+		}
+		fmt.Printf("Got %d of %d species before: %v\nRun names again to carry on\n", len(index.Done), index.Total, err)
+		return nil
+	}
+	fmt.Printf("Know the names of %d species in every language\n", len(index.Done))
+	return nil
+}
+
+func saveLocalNameIndex(index *localNameIndex) {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return
+	}
+	path := dataPath("local-names.json")
+	os.MkdirAll(filepath.Dir(path), 0700)
+	os.WriteFile(path, data, 0600)
+}
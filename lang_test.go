@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"pokedexcli/internal"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseLanguage(t *testing.T) {
+	cases := map[string]string{"ja": "ja", "FR": "fr", "zh-hans": "zh-Hans", "off": ""}
+	for value, expected := range cases {
+		lang, err := parseLanguage(value)
+		if err != nil || lang != expected {
+			t.Errorf("%s: expected %q, got %q, %v", value, expected, lang, err)
+		}
+	}
+	if _, err := parseLanguage("klingon"); err == nil {
+		t.Errorf("expected an error for an unknown language")
+	}
+}
+
+func TestPickName(t *testing.T) {
+	names := []localizedName{
+		{Language: namedResource{Name: "ja"}, Name: "ピカチュウ"},
+		{Language: namedResource{Name: "en"}, Name: "Pikachu"},
+	}
+	defer func(saved string) { LANGUAGE = saved }(LANGUAGE)
+	cases := []struct {
+		lang     string
+		names    []localizedName
+		expected string
+	}{
+		{lang: "", names: names, expected: "pikachu"},
+		{lang: "ja", names: names, expected: "ピカチュウ"},
+		{lang: "fr", names: names, expected: "Pikachu"},
+		{lang: "fr", expected: "pikachu"},
+	}
+	for _, c := range cases {
+		LANGUAGE = c.lang
+		if name := pickName(c.names, "pikachu"); name != c.expected {
+			t.Errorf("%q: expected %q, got %q", c.lang, c.expected, name)
+		}
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	rememberLocalNames([]localizedName{
+		{Language: namedResource{Name: "fr"}, Name: "M. Mime"},
+		{Language: namedResource{Name: "de"}, Name: "Pantimos"},
+	}, "mr-mime")
+	defer delete(LOCAL_NAMES, "m. mime")
+	defer delete(LOCAL_NAMES, "pantimos")
+	savedLocal := LOCAL_INDEX
+	defer func() { LOCAL_INDEX = savedLocal }()
+	LOCAL_INDEX = &localNameIndex{Fetched: time.Now(), Names: map[string]string{"ピカチュウ": "pikachu"}}
+	cases := []struct {
+		words    []string
+		expected string
+	}{
+		{words: []string{"m.", "mime"}, expected: "mr-mime"},
+		{words: []string{"pantimos"}, expected: "mr-mime"},
+		{words: []string{"mr-mime"}, expected: "mr-mime"},
+		{words: []string{"Pikachu", "extra"}, expected: "pikachu"},
+		{words: []string{"ピカチュウ"}, expected: "pikachu"},
+	}
+	for _, c := range cases {
+		if name := resolveName(c.words); name != c.expected {
+			t.Errorf("%v: expected %q, got %q", c.words, c.expected, name)
+		}
+	}
+}
+
+// countingTransport counts the requests made through it, failing them all.
+type countingTransport struct {
+	requests *atomic.Int32
+}
+
+func (transport countingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	transport.requests.Add(1)
+	return nil, errors.New("no requests expected")
+}
+
+func TestResolveNameOffline(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	var requests atomic.Int32
+	API = internal.NewClient(time.Second)
+	API.HTTPClient = &http.Client{Transport: countingTransport{&requests}}
+	savedIndex, savedLocal, savedLanguage := NAME_INDEX, LOCAL_INDEX, LANGUAGE
+	defer func() { NAME_INDEX, LOCAL_INDEX, LANGUAGE = savedIndex, savedLocal, savedLanguage }()
+	NAME_INDEX, LOCAL_INDEX = nil, nil
+	for _, lang := range []string{"", "ja"} {
+		LANGUAGE = lang
+		for _, words := range [][]string{{"pikachuu"}, {"64"}, {"ピカチュウ"}} {
+			if name := resolveName(words); name != words[0] {
+				t.Errorf("%v with lang %q: expected %q, got %q", words, lang, words[0], name)
+			}
+		}
+	}
+	if requests.Load() != 0 {
+		t.Errorf("expected no requests, got %d", requests.Load())
+	}
+}
+
+// speciesTransport answers like PokeAPI for a few species, failing those
+// in down.
+type speciesTransport struct {
+	down map[string]bool
+}
+
+func (transport speciesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	name := strings.TrimPrefix(req.URL.Path, "/api/v2/pokemon-species/")
+	switch {
+	case name == "":
+		body = `{"count": 2, "results": [{"name": "pikachu"}, {"name": "mr-mime"}]}`
+	case transport.down[name]:
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	case name == "pikachu":
+		body = `{"names": [{"language": {"name": "ja-Hrkt"}, "name": "ピカチュウ"}]}`
+	case name == "mr-mime":
+		body = `{"names": [{"language": {"name": "de"}, "name": "Pantomimi"}]}`
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestLocalNameIndex(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	API.MaxAttempts = 1
+	newCaches()
+	savedLocal := LOCAL_INDEX
+	defer func() { LOCAL_INDEX = savedLocal }()
+	LOCAL_INDEX = nil
+	ctx := context.Background()
+
+	// what's fetched before an error is kept and not fetched again
+	API.HTTPClient = &http.Client{Transport: speciesTransport{down: map[string]bool{"mr-mime": true}}}
+	index, err := buildLocalNameIndex(ctx, false)
+	if err == nil {
+		t.Fatalf("expected an error while mr-mime can't be fetched")
+	}
+	if index.Names["ピカチュウ"] != "pikachu" || !index.Fetched.IsZero() {
+		t.Errorf("expected pikachu to be indexed and the index unfinished, got %+v", index)
+	}
+
+	LOCAL_INDEX = nil
+	API.HTTPClient = &http.Client{Transport: speciesTransport{}}
+	index, err = buildLocalNameIndex(ctx, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if index.Names["pantomimi"] != "mr-mime" || index.Names["ピカチュウ"] != "pikachu" || index.Fetched.IsZero() {
+		t.Errorf("expected a finished index, got %+v", index)
+	}
+}
+
+func TestLookupEach(t *testing.T) {
+	var running, most atomic.Int32
+	seen := make([]bool, 50)
+	lookupEach(len(seen), func(i int) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		seen[i] = true
+	})
+	if most.Load() > NAME_WORKERS {
+		t.Errorf("expected at most %d lookups at once, got %d", NAME_WORKERS, most.Load())
+	}
+	for i, done := range seen {
+		if !done {
+			t.Errorf("lookup %d didn't run", i)
+		}
+	}
+}
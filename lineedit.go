@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const historyLimit = 1000
+
+// errInterrupted is returned by readLine when Ctrl-C abandons the line.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads REPL input with emacs-style editing, history recall,
+// Ctrl-R search and tab completion. When stdin isn't a terminal it just
+// reads plain lines.
+type lineEditor struct {
+	in          *bufio.Reader
+	out         io.Writer
+	fd          int
+	history     []string
+	historyPath string
+	rawMu       sync.Mutex
+	restore     func() // set while the terminal is raw
+	// complete returns the candidates for partial, the word under the
+	// cursor, given the words typed before it.
+	complete func(words []string, partial string) []string
+}
+
+func newLineEditor(in *os.File, out io.Writer, historyPath string, complete func(words []string, partial string) []string) *lineEditor {
+	ed := &lineEditor{
+		in:          bufio.NewReader(in),
+		out:         out,
+		fd:          int(in.Fd()),
+		historyPath: historyPath,
+		complete:    complete,
+	}
+	ed.loadHistory()
+	return ed
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+func (ed *lineEditor) readLine(prompt string) (string, error) {
+	restore, rawErr := makeRaw(ed.fd)
+	if rawErr != nil {
+		fmt.Fprint(ed.out, prompt)
+		line, err := ed.in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	ed.rawMu.Lock()
+	ed.restore = restore
+	ed.rawMu.Unlock()
+	line, err := ed.edit(prompt)
+	ed.restoreTerminal()
+	if err == nil {
+		ed.addHistory(line)
+	}
+	return line, err
+}
+
+// restoreTerminal takes the terminal out of raw mode if readLine left it
+// there. It is safe to call from a signal handler mid-read.
+func (ed *lineEditor) restoreTerminal() {
+	ed.rawMu.Lock()
+	defer ed.rawMu.Unlock()
+	if ed.restore != nil {
+		ed.restore()
+		ed.restore = nil
+	}
+}
+
+// edit runs the key loop for one line. The terminal must already be raw.
+func (ed *lineEditor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	histIdx := len(ed.history)
+	var pending []rune // what was typed before browsing history
+	recall := func(idx int) {
+		if histIdx == len(ed.history) {
+			pending = buf
+		}
+		histIdx = idx
+		if histIdx == len(ed.history) {
+			buf = pending
+		} else {
+			buf = []rune(ed.history[histIdx])
+		}
+		pos = len(buf)
+	}
+
+	ed.refresh(prompt, buf, pos)
+	for {
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r == 27 {
+			r = ed.readEscape()
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return string(buf), nil
+		case ctrl('C'):
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(buf) == 0 {
+				fmt.Fprint(ed.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos:pos], buf[pos+1:]...)
+			}
+		case 127, ctrl('H'):
+			if pos > 0 {
+				buf = append(buf[:pos-1:pos-1], buf[pos:]...)
+				pos--
+			}
+		case ctrl('A'):
+			pos = 0
+		case ctrl('E'):
+			pos = len(buf)
+		case ctrl('B'):
+			pos = max(pos-1, 0)
+		case ctrl('F'):
+			pos = min(pos+1, len(buf))
+		case ctrl('K'):
+			buf = buf[:pos]
+		case ctrl('U'):
+			buf = append([]rune(nil), buf[pos:]...)
+			pos = 0
+		case ctrl('W'):
+			start := pos
+			for start > 0 && buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && buf[start-1] != ' ' {
+				start--
+			}
+			buf = append(buf[:start:start], buf[pos:]...)
+			pos = start
+		case ctrl('L'):
+			fmt.Fprint(ed.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			if histIdx > 0 {
+				recall(histIdx - 1)
+			}
+		case ctrl('N'):
+			if histIdx < len(ed.history) {
+				recall(histIdx + 1)
+			}
+		case ctrl('R'):
+			found, accept, err := ed.search(buf)
+			if err != nil {
+				return "", err
+			}
+			buf = found
+			pos = len(buf)
+			if accept {
+				ed.refresh(prompt, buf, pos)
+				fmt.Fprint(ed.out, "\r\n")
+				return string(buf), nil
+			}
+		case '\t':
+			buf, pos = ed.completeAt(prompt, buf, pos)
+		case keyDelete:
+			if pos < len(buf) {
+				buf = append(buf[:pos:pos], buf[pos+1:]...)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		ed.refresh(prompt, buf, pos)
+	}
+}
+
+// keyDelete stands in for the Delete key's escape sequence; it is outside
+// the range of runes a terminal sends.
+const keyDelete = -2
+
+// readEscape reads the rest of an escape sequence after ESC and turns the
+// keys we handle into their control key equivalents.
+func (ed *lineEditor) readEscape() rune {
+	intro, _, err := ed.in.ReadRune()
+	if err != nil || (intro != '[' && intro != 'O') {
+		return 0
+	}
+	var params []rune
+	for {
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if r >= 0x40 && r <= 0x7e {
+			switch string(params) + string(r) {
+			case "A":
+				return ctrl('P')
+			case "B":
+				return ctrl('N')
+			case "C":
+				return ctrl('F')
+			case "D":
+				return ctrl('B')
+			case "H", "1~", "7~":
+				return ctrl('A')
+			case "F", "4~", "8~":
+				return ctrl('E')
+			case "3~":
+				return keyDelete
+			}
+			return 0
+		}
+		params = append(params, r)
+	}
+}
+
+func (ed *lineEditor) refresh(prompt string, buf []rune, pos int) {
+	fmt.Fprintf(ed.out, "\r%s%s\x1b[K", prompt, string(buf))
+	if back := len(buf) - pos; back > 0 {
+		fmt.Fprintf(ed.out, "\x1b[%dD", back)
+	}
+}
+
+// search runs a Ctrl-R reverse incremental search through the history. It
+// returns the chosen line and whether Enter was pressed to run it straight
+// away.
+func (ed *lineEditor) search(orig []rune) ([]rune, bool, error) {
+	var query []rune
+	idx := len(ed.history)
+	match := ""
+	find := func(from int) bool {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(ed.history[i], string(query)) {
+				idx = i
+				match = ed.history[i]
+				return true
+			}
+		}
+		return false
+	}
+	for {
+		label := "reverse-i-search"
+		if match == "" && len(query) > 0 {
+			label = "failed reverse-i-search"
+		}
+		fmt.Fprintf(ed.out, "\r(%s)`%s': %s\x1b[K", label, string(query), match)
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case r == ctrl('R'):
+			// with no older match the current one stays
+			find(idx - 1)
+		case r == 127 || r == ctrl('H'):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = ""
+				find(len(ed.history) - 1)
+			}
+		case r == '\r' || r == '\n':
+			return []rune(match), true, nil
+		case r == ctrl('G') || r == ctrl('C'):
+			return orig, false, nil
+		case r == 27:
+			ed.readEscape()
+			return []rune(match), false, nil
+		case unicode.IsPrint(r):
+			query = append(query, r)
+			if !find(min(idx, len(ed.history)-1)) {
+				match = ""
+			}
+		default:
+			return []rune(match), false, nil
+		}
+	}
+}
+
+// completeAt completes the word before the cursor. A single candidate is
+// filled in; several are narrowed to their common prefix, or listed when
+// that doesn't get any further.
+func (ed *lineEditor) completeAt(prompt string, buf []rune, pos int) ([]rune, int) {
+	if ed.complete == nil {
+		return buf, pos
+	}
+	before := string(buf[:pos])
+	words := strings.Fields(before)
+	partial := ""
+	if len(words) > 0 && !strings.HasSuffix(before, " ") {
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	candidates := ed.complete(words, partial)
+	if len(candidates) == 0 {
+		fmt.Fprint(ed.out, "\a")
+		return buf, pos
+	}
+	insert := commonPrefix(candidates)[len(partial):]
+	if len(candidates) == 1 {
+		insert += " "
+	} else if insert == "" {
+		fmt.Fprintf(ed.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return buf, pos
+	}
+	buf = append(buf[:pos:pos], append([]rune(insert), buf[pos:]...)...)
+	return buf, pos + len([]rune(insert))
+}
+
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
+
+func (ed *lineEditor) loadHistory() {
+	data, err := os.ReadFile(ed.historyPath)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > historyLimit {
+		lines = lines[len(lines)-historyLimit:]
+		os.WriteFile(ed.historyPath, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	}
+	for _, line := range lines {
+		if line != "" {
+			ed.history = append(ed.history, line)
+		}
+	}
+}
+
+// addHistory records line, skipping blanks and immediate repeats, and
+// appends it to the history file. History is a convenience, so failing to
+// write it is not an error.
+func (ed *lineEditor) addHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (len(ed.history) > 0 && ed.history[len(ed.history)-1] == line) {
+		return
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > historyLimit {
+		ed.history = ed.history[1:]
+	}
+	if ed.historyPath == "" {
+		return
+	}
+	os.MkdirAll(filepath.Dir(ed.historyPath), 0700)
+	file, err := os.OpenFile(ed.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
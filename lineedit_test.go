@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testEditor(input string, history ...string) *lineEditor {
+	return &lineEditor{
+		in:       bufio.NewReader(strings.NewReader(input)),
+		out:      io.Discard,
+		history:  history,
+		complete: completeWord,
+	}
+}
+
+func TestEdit(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		history  []string
+		expected string
+	}{
+		{name: "typing", input: "map\r", expected: "map"},
+		{name: "backspace", input: "mapp\x7f\r", expected: "map"},
+		{name: "cursor keys", input: "zmp\x1b[Da\x1b[H\x1b[3~\r", expected: "map"},
+		{name: "home and kill", input: "xx\x01\x0bmap\r", expected: "map"},
+		{name: "delete word", input: "catch bulba\x17pikachu\r", expected: "catch pikachu"},
+		{name: "history up", input: "\x1b[A\x1b[A\r", history: []string{"map", "mapb"}, expected: "map"},
+		{name: "history down restores typing", input: "ex\x1b[A\x1b[B\r", history: []string{"map"}, expected: "ex"},
+		{name: "reverse search", input: "\x12cat\r", history: []string{"catch pikachu", "map"}, expected: "catch pikachu"},
+		{name: "reverse search older match", input: "\x12a\x12\x1b[C\r", history: []string{"catch pikachu", "map"}, expected: "catch pikachu"},
+		{name: "reverse search stops matching", input: "\x12catx\r", history: []string{"catch pikachu", "map"}, expected: ""},
+		{name: "reverse search matches again", input: "\x12catx\x7f\r", history: []string{"catch pikachu", "map"}, expected: "catch pikachu"},
+		{name: "complete command", input: "insp\t\r", expected: "inspect "},
+		{name: "complete common prefix", input: "ma\t\r", expected: "map"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual, err := testEditor(c.input, c.history...).edit("> ")
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if actual != c.expected {
+				t.Errorf("EXPECTED: %q\tACTUAL: %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	cases := []struct {
+		words    []string
+		expected string
+	}{
+		{words: []string{"map", "mapb"}, expected: "map"},
+		{words: []string{"ポケモン", "ポケット"}, expected: "ポケ"},
+		{words: []string{"pikachu", "ピカチュウ"}, expected: ""},
+	}
+	for _, c := range cases {
+		if actual := commonPrefix(c.words); actual != c.expected {
+			t.Errorf("%v: expected %q, got %q", c.words, c.expected, actual)
+		}
+	}
+}
+
+func TestEditInterruptAndEOF(t *testing.T) {
+	_, err := testEditor("catch\x03").edit("> ")
+	if err != errInterrupted {
+		t.Errorf("expected errInterrupted, got %v", err)
+	}
+	_, err = testEditor("\x04").edit("> ")
+	if err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -3,30 +3,49 @@ package main
 import (
 	"fmt"
 	"strings"
-	"bufio"
+	"path/filepath"
 	"os"
-	"net/http"
 	"encoding/json"
+	"context"
 	"io"
-	"log"
 	"pokedexcli/internal"
 	"time"
-	"strconv"
 	"math"
 	"math/rand"
 )
 
-var MAP_INDEX int
-var MAP_CACHE internal.Cache
-var EXPLORE_CACHE internal.Cache
-var CATCH_CACHE internal.Cache
+var MAP_PAGE = mapPage{size: 20}
+var MAP_CACHE *internal.Cache[string, locationArea] // keyed by page URL
+var REGION_CACHE *internal.Cache[string, region]
+var LOCATION_CACHE *internal.Cache[string, location]
+var EXPLORE_CACHE *internal.Cache[string, locationAreaLocation]
+var CATCH_CACHE *internal.Cache[string, pokemonEntry]
+var SPRITE_CACHE *internal.ByteCache
+var WHERE_CACHE *internal.Cache[string, []areaEncounters]
 var POKEMON map[string]pokemonEntry
-var CAUGHT map[string]struct{}
+var CAUGHT map[string]caughtRecord
+var SPECIES map[string]pokemonSpecies
+var API *internal.Client
+var OUTPUT_JSON bool // set by --json on the current command
+
+// How long API data stays fresh, and how much longer it may be served
+// stale while it is fetched again. Pokemon and sprites effectively never
+// change; location lists rarely do.
+const (
+	POKEMON_TTL = time.Hour*24
+	POKEMON_STALE = time.Hour*24*7
+	AREA_TTL = time.Hour
+	AREA_STALE = time.Hour*24
+	// Misspelled names are remembered for less time than real data, in
+	// case they're about to be added upstream.
+	MISSING_TTL = time.Minute
+	REAP_INTERVAL = time.Minute
+)
 
 type cliCommand struct {
 	name string
 	description string
-	callback func(location string) error
+	callback func(ctx context.Context, args []string) error
 }
 
 type locationArea struct {
@@ -60,35 +79,13 @@ type locationAreaLocation struct {
 		URL  string `json:"url"`
 	} `json:"location"`
 	Name  string `json:"name"`
-	Names []struct {
-		Language struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"language"`
-		Name string `json:"name"`
-	} `json:"names"`
+	Names []localizedName `json:"names"`
 	PokemonEncounters []struct {
 		Pokemon struct {
 			Name string `json:"name"`
 			URL  string `json:"url"`
 		} `json:"pokemon"`
-		VersionDetails []struct {
-			EncounterDetails []struct {
-				Chance          int   `json:"chance"`
-				ConditionValues []any `json:"condition_values"`
-				MaxLevel        int   `json:"max_level"`
-				Method          struct {
-					Name string `json:"name"`
-					URL  string `json:"url"`
-				} `json:"method"`
-				MinLevel int `json:"min_level"`
-			} `json:"encounter_details"`
-			MaxChance int `json:"max_chance"`
-			Version   struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version"`
-		} `json:"version_details"`
+		VersionDetails []versionEncounters `json:"version_details"`
 	} `json:"pokemon_encounters"`
 }
 
@@ -106,17 +103,17 @@ func createRegistry() map[string]cliCommand{
 		},
 		"map": {
 			name:"map",
-			description: "Displays the next 20 location names.",
+			description: "Displays the next page of location names, type map [--page <n>] [--size <n>] [--region <name>|all]",
 			callback: pokeMap,
 		},
 		"mapb": {
 			name:"mapb",
-			description: "Displays the previous 20 location names.",
+			description: "Displays the previous page of location names.",
 			callback: pokeMapB,
 		},
 		"explore": {
 			name:"explore",
-			description:"Show pokemon at location, type explore <location>",
+			description:"Show pokemon at location, type explore <location> [--details] [--version <name>] [--method <name>]",
 			callback:exploreMap,
 		},
 		"catch": {
@@ -126,183 +123,312 @@ func createRegistry() map[string]cliCommand{
 		},
 		"inspect": {
 			name:"inspect",
-			description:"Inspect a pokemon, type inspect <pokemon name>",
+			description:"Inspect a pokemon, type inspect <pokemon name> [--section abilities|moves|species|all] [--version-group <name>] [--sprite]",
 			callback:inspect,
 		},
+		"where": {
+			name:"where",
+			description:"List where a pokemon can be found, type where <pokemon name> [--version <name>] [--explore <n>]",
+			callback:where,
+		},
+		"sprite": {
+			name:"sprite",
+			description:"Draw a pokemon, type sprite <pokemon name> [--shiny] [--back] [--gen i-viii] [--color truecolor|256|ascii]",
+			callback:sprite,
+		},
+		"search": {
+			name:"search",
+			description:"Find pokemon and location areas by name, type search <query> [--pokemon|--areas] [--limit <n>]",
+			callback:search,
+		},
+		"set": {
+			name:"set",
+			description:"Show or change settings, type set [<setting> [<value>]]",
+			callback:commandSet,
+		},
+		"regions": {
+			name:"regions",
+			description:"List the regions",
+			callback:listRegions,
+		},
+		"locations": {
+			name:"locations",
+			description:"List the locations in a region, type locations <region>",
+			callback:listLocations,
+		},
+		"areas": {
+			name:"areas",
+			description:"List the areas of a location, type areas <location>",
+			callback:listAreas,
+		},
+		"log": {
+			name:"log",
+			description:"Show the encounter log: [pokemon] [--event seen|caught|escaped|traded|received] [--area <name>] [--since <24h|date>] [--limit <n>]",
+			callback:encounterLog,
+		},
+		"trainer": {
+			name:"trainer",
+			description:"Show your trainer, or list, create or switch between trainers: [list | new <name> | switch <name>]",
+			callback:trainerCommand,
+		},
+		"trade": {
+			name:"trade",
+			description:"Trade a caught pokemon to another trainer: export <pokemon|id> [--out <file>] | import <file>",
+			callback:trade,
+		},
 		"pokedex": {
 			name:"pokedex",
-			description:"List caught pokemon names",
+			description:"List caught pokemon with --sort and --filter, or progress through a pokedex with --dex",
 			callback:pPokedex,
 		},
+		"names": {
+			name:"names",
+			description:"Look up pokemon names in every language so they can be typed in any, type names [--refresh]",
+			callback:learnNames,
+		},
 	}
 }
 
-func printMaps(offset int, limit int ) error {
-	mapBytes, isCached := MAP_CACHE.Get(strconv.Itoa(offset))
-	if (isCached) {
-		fmt.Println(string(mapBytes))
-		return nil
-	}
-
-
-	query := fmt.Sprintf("https://pokeapi.co/api/v2/location-area/?offset=%v&limit=%v",offset,limit)
-	resp,httpErr := http.Get(query)
-	if (httpErr != nil) {
-		return httpErr
-	}
-	body, readErr := io.ReadAll(resp.Body)
-	if (readErr != nil)  {
-		return readErr
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode > 299 {
-		log.Fatalf("Failed %d and %s",resp.StatusCode, body)
+func pokeMap(ctx context.Context, args []string) error {
+	_, flags := parseArgs(args)
+	page := MAP_PAGE
+	jump, flagErr := mapFlags(&page, flags)
+	if (flagErr != nil) {
+		return flagErr
 	}
-	var la locationArea
-	jErr := json.Unmarshal(body, &la)
-	if (jErr != nil) {
-		return jErr
+	link := ""
+	if (!jump) {
+		if (page.region == "" && page.next == "") || (page.region != "" && page.offset+page.size >= page.count) {
+			fmt.Println("You're on the last page")
+			return nil
+		}
+		if (page.region == "") {
+			link = page.next
+		} else {
+			page.offset += page.size
+		}
 	}
-	var mapList string
-	for _,obj := range la.Results {
-		mapList = mapList + fmt.Sprintf("%s\n",obj.Name)
+	shown, err := showMapPage(ctx, &page, link)
+	if (shown) {
+		MAP_PAGE = page
+		TRAINER_CHANGED = true
 	}
-	MAP_CACHE.Add(strconv.Itoa(offset), []byte(mapList))
-	fmt.Println(mapList)
-	return nil
-}
-
-func pokeMap(location string) error {
-	MAP_INDEX++
-	limit := 20
-	offset := 20 * MAP_INDEX
-	err := printMaps(offset, limit)
 	return err
 }
 
-func pokeMapB(location string) error {
-	limit := 20
-	mapIndex := MAP_INDEX-1
-	if (MAP_INDEX-1 < 0) {
-		mapIndex = 0
-		if (MAP_INDEX-1 == 0) {
-			MAP_INDEX--
-		}
+func pokeMapB(ctx context.Context, args []string) error {
+	page := MAP_PAGE
+	if (!page.shown || page.offset == 0) {
+		fmt.Println("You're on the first page")
+		return nil
+	}
+	link := ""
+	if (page.region == "") {
+		link = page.previous
 	} else {
-		MAP_INDEX--
+		page.offset = max(page.offset-page.size, 0)
+	}
+	shown, err := showMapPage(ctx, &page, link)
+	if (shown) {
+		MAP_PAGE = page
+		TRAINER_CHANGED = true
 	}
-	offset := 20 * mapIndex
-	err := printMaps(offset, limit)
 	return err
+}
 
+func fetchArea(ctx context.Context, location string) (locationAreaLocation, internal.LookupResult, error) {
+	return EXPLORE_CACHE.Fetch(ctx, location, AREA_TTL, AREA_STALE, func(ctx context.Context) (locationAreaLocation, error) {
+		var lal locationAreaLocation
+		query := fmt.Sprintf("https://pokeapi.co/api/v2/location-area/%s",location)
+		return lal, API.GetJSON(ctx, query, &lal)
+	})
 }
 
-func printPokemon(location string) error {
-	monBytes, isCached := EXPLORE_CACHE.Get(location)
-	if (isCached) {
-		fmt.Println(string(monBytes))
+// exploredArea is what explore --json shows without --details.
+type exploredArea struct {
+	Area    string   `json:"area"`
+	Pokemon []string `json:"pokemon"`
+}
+
+func printPokemon(ctx context.Context, location string, flags flagSet) error {
+	lal, lookup, apiErr := fetchArea(ctx, location)
+	if (apiErr != nil) {
+		return apiErr
+	}
+	noteStale(lookup, location)
+	if lookup == internal.KnownMissing {
+		fmt.Printf("Location area %s not found%s\n", location, didYouMean(ctx, location, false))
 		return nil
 	}
 
-
-	query := fmt.Sprintf("https://pokeapi.co/api/v2/location-area/%s",location)
-	resp,httpErr := http.Get(query)
-	if (httpErr != nil) {
-		return httpErr
+	for _,obj := range lal.PokemonEncounters {
+		KNOWN_POKEMON[obj.Pokemon.Name] = struct{}{}
 	}
-	body, readErr := io.ReadAll(resp.Body)
-	if (readErr != nil)  {
-		return readErr
+	version, method := flags.get("version"), flags.get("method")
+	if (version == "") {
+		version = GAME
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode > 299 {
-		log.Fatalf("Failed %d and %s",resp.StatusCode, body)
+	rows := encounterRows(lal, version, method)
+	listed := make(map[string]bool)
+	for _,row := range rows {
+		listed[row.Pokemon] = true
 	}
-	var lal locationAreaLocation
-	jErr := json.Unmarshal(body, &lal)
-	if (jErr != nil) {
-		return jErr
+	var names, sighted []string
+	for _,obj := range lal.PokemonEncounters {
+		names = append(names, obj.Pokemon.Name)
+		if (listed[obj.Pokemon.Name] || (version == "" && method == "")) {
+			sighted = append(sighted, obj.Pokemon.Name)
+		}
 	}
+	recordSightings(lal.Name, sighted)
+	if (OUTPUT_JSON && flags.has("details")) {
+		return printJSON(rows)
+	}
+	if (OUTPUT_JSON) {
+		if (sighted == nil) {
+			sighted = []string{}
+		}
+		return printJSON(exploredArea{Area: lal.Name, Pokemon: sighted})
+	}
+
+	local := make(map[string]string)
+	for i,name := range localNames(ctx, "pokemon-species", names) {
+		local[names[i]] = name
+	}
+
 	var monList string
-	monList += fmt.Sprintf("Exploring %s...\n", location)
+	monList += fmt.Sprintf("Exploring %s...\n", pickName(lal.Names, location))
+	monList += fmt.Sprintf("In %s\n", areaPath(ctx, lal))
+	if (len(rows) == 0 && (version != "" || method != "")) {
+		if (version != "" && method == "") {
+			monList += fmt.Sprintf("No encounters in %s\n", version)
+		} else {
+			monList += fmt.Sprintf("No encounters match\n")
+		}
+		fmt.Println(monList)
+		return nil
+	}
+	if (flags.has("details")) {
+		fmt.Print(monList)
+		for i := range rows {
+			rows[i].Pokemon = local[rows[i].Pokemon]
+		}
+		printEncounterTable(rows)
+		return nil
+	}
 	monList += fmt.Sprintf("Found Pokemon:\n")
-	for _,obj := range lal.PokemonEncounters {
-		monList = monList + fmt.Sprintf(" - %s\n",obj.Pokemon.Name)
+	for _,name := range sighted {
+		monList = monList + fmt.Sprintf(" - %s\n",local[name])
 	}
-	EXPLORE_CACHE.Add(location, []byte(monList))
 	fmt.Println(monList)
 	return nil
 }
 
-func exploreMap(location string) error {
-	return printPokemon(location)
-}
-
-func catch(name string) error {
-	fmt.Printf("Throwing a Pokeball at %s...\n",name)
-	monBytes, isCached := EXPLORE_CACHE.Get(name)
-	if (isCached) {
-		if monBytes != nil {
-			bexp,_ := strconv.Atoi(string(monBytes))
-			chance := (1/(math.Log(float64(bexp))))*100
-			isCaught := roll(chance)
-			if isCaught {
-				CAUGHT[name]=struct{}{}
-				fmt.Printf("%s was caught!\n",name)
-			} else {
-				fmt.Printf("%s escaped!\n", name)
-			}
-		} else {
-			fmt.Printf("Pokemon %s not found in pokedex\n", name)
-		}
+func exploreMap(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args, "details")
+	if len(words) < 1 {
+		fmt.Println("Usage: explore <location> [--details] [--version <name>] [--method <name>]")
 		return nil
 	}
+	return printPokemon(ctx, words[0], flags)
+}
 
-	query := fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%s",name)
-	resp,httpErr := http.Get(query)
-	if (httpErr != nil) {
-		return httpErr
-	}
-	if resp.StatusCode == 404 {
-		fmt.Printf("Pokemon %s not found in pokedex\n", name)
-		EXPLORE_CACHE.Add(name, nil)
+func catch(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		fmt.Println("Usage: catch <pokemon name>")
 		return nil
 	}
-	body, readErr := io.ReadAll(resp.Body)
-	if (readErr != nil)  {
-		return readErr
+	name := resolveName(args)
+	displayName := localName(ctx, "pokemon-species", name)
+	fmt.Printf("Throwing a Pokeball at %s...\n",displayName)
+	mon, lookup, apiErr := fetchPokemon(ctx, name)
+	if (apiErr != nil) {
+		return apiErr
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode > 299 {
-		log.Fatalf("Failed %d and %s",resp.StatusCode, body)
+	if lookup == internal.KnownMissing {
+		fmt.Printf("Pokemon %s not found in pokedex%s\n", name, didYouMean(ctx, name, true))
+		return nil
 	}
 
-	var mon pokemonEntry
-	jErr := json.Unmarshal(body, &mon)
-	if (jErr != nil) {
-		return jErr
+	info, gameErr := activeGame(ctx)
+	if (gameErr != nil) {
+		return gameErr
+	}
+	if (!info.catchable(mon)) {
+		fmt.Printf("%s isn't in the %s pokedex\n", displayName, info.Version)
+		return nil
 	}
 
 	POKEMON[name]=mon
-	EXPLORE_CACHE.Add(name, []byte(strconv.Itoa(mon.BaseExperience)))	
-	
+	KNOWN_POKEMON[name]=struct{}{}
+	TRAINER_CHANGED = true
+
 	chance := (1/(math.Log(float64(mon.BaseExperience))))*100
 	isCaught := roll(chance)
+	event := encounterEvent{Event: "escaped", Pokemon: name, Game: GAME}
+	if (SEEN[name][CURRENT_AREA]) {
+		event.Area = CURRENT_AREA
+	}
 	if isCaught {
-		CAUGHT[name]=struct{}{}
-		fmt.Printf("%s was caught!\n",name)
+		event.Event = "caught"
+	}
+	recordEncounter(event)
+	if isCaught {
+		if _, exists := CAUGHT[name]; !exists {
+			// the level is only known for pokemon caught where they were
+			// met exploring
+			level := 0
+			if (event.Area != "") {
+				lal, _, areaErr := fetchArea(ctx, event.Area)
+				if (areaErr == nil) {
+					level = encounterLevel(lal, name, GAME)
+				}
+			}
+			CAUGHT[name]=caughtRecord{CaughtAt: time.Now(), Level: level}
+			joinParty(name)
+		}
+		fmt.Printf("%s was caught!\n",displayName)
 	} else {
-		fmt.Printf("%s escaped!\n", name)
+		fmt.Printf("%s escaped!\n", displayName)
 	}
 	return nil
 }
 
+// fetchPokemon returns the pokemon from CATCH_CACHE, fetching it when it
+// isn't there. It doesn't look in POKEMON.
+func fetchPokemon(ctx context.Context, name string) (pokemonEntry, internal.LookupResult, error) {
+	mon, lookup, apiErr := CATCH_CACHE.Fetch(ctx, name, POKEMON_TTL, POKEMON_STALE, func(ctx context.Context) (pokemonEntry, error) {
+		var mon pokemonEntry
+		query := fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%s",name)
+		return mon, API.GetJSON(ctx, query, &mon)
+	})
+	noteStale(lookup, name)
+	return mon, lookup, apiErr
+}
+
+// noteStale says in verbose mode when we answered from stale data.
+func noteStale(lookup internal.LookupResult, what string) {
+	if lookup == internal.Stale {
+		verbosef("using cached %s while it is refreshed", what)
+	}
+}
+
 func roll(pct float64) bool {
 	rand.Seed(time.Now().UnixNano())
 	return rand.Intn(100) < int(pct)
 }
 
-func help(location string) error {
+// printJSON writes v to stdout as indented JSON, for commands run with --json.
+func printJSON(v any) error {
+	out, jErr := json.MarshalIndent(v, "", "  ")
+	if (jErr != nil) {
+		return jErr
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func help(ctx context.Context, args []string) error {
 	cmdMap := createRegistry()
 	fmt.Println("Commands")
 	fmt.Println("name: description")
@@ -313,50 +439,68 @@ func help(location string) error {
 	return nil
 }
 
-func inspect(name string) error {
-	mon,exists := POKEMON[name]
-	if  exists {
-		fmt.Printf("Name: %s\n", name)
-		fmt.Printf("height: %v\n", mon.Height)
-		fmt.Printf("weight: %v\n", mon.Weight)
-		fmt.Printf("stats:\n")
-		for _,stat := range mon.Stats {
-			if stat.Stat.Name == "hp" || stat.Stat.Name == "attack" || stat.Stat.Name == "defense" || stat.Stat.Name == "special-attack" || stat.Stat.Name == "special-defense" || stat.Stat.Name == "speed" {
-				fmt.Printf("  -%s: %v\n",stat.Stat.Name, stat.BaseStat)
-			}
-		}
-		fmt.Printf("types:\n")
-		for _,pType := range mon.Types {
-			fmt.Printf("  - %s\n",pType.Type.Name)
-		}
-	} else {
-		fmt.Printf("Unknown pokemon, try catching one with catch %s\n", name)
+func pPokedex(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args)
+	missing := len(words) > 0 && words[0] == "missing"
+	if (len(words) > 0 && !missing) {
+		fmt.Println("Usage: pokedex [missing] [--dex <national|region>] [--sort id|name|caught-at|level|bst] [--filter type=<type>|gen=<n>] [--page <n>] [--size <n>]")
+		return nil
 	}
-	return nil
-}
-
-func pPokedex(unused string) error {
-	fmt.Println("Your Pokedex:")
-	for key,_ := range CAUGHT {
-		fmt.Printf(" - %s\n",key)
+	if (missing || flags.has("dex")) {
+		dex := flags.get("dex")
+		if (dex == "") {
+			dex = "national"
+		}
+		return showDex(ctx, dex, missing)
 	}
-	return nil
+	return listCaught(ctx, flags)
 } 
 
+// newCaches makes every cache, each with its reaper running.
+func newCaches() {
+	MAP_CACHE = internal.NewTypedCache[string, locationArea](REAP_INTERVAL)
+	REGION_CACHE = internal.NewTypedCache[string, region](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+	LOCATION_CACHE = internal.NewTypedCache[string, location](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+	EXPLORE_CACHE = internal.NewTypedCache[string, locationAreaLocation](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+	CATCH_CACHE = internal.NewTypedCache[string, pokemonEntry](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+	SPRITE_CACHE = internal.NewCache(REAP_INTERVAL)
+	NAMES_CACHE = internal.NewTypedCache[string, []localizedName](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+	WHERE_CACHE = internal.NewTypedCache[string, []areaEncounters](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+	DEX_CACHE = internal.NewTypedCache[string, pokedexList](REAP_INTERVAL, internal.WithMissingTTL(MISSING_TTL))
+}
+
 func main() {
-	MAP_INDEX = -1 // Redundant, but do note.
-	MAP_CACHE = internal.NewCache(time.Second*5)
-	EXPLORE_CACHE = internal.NewCache(time.Second*5)
-	CATCH_CACHE = internal.NewCache(time.Second*5)
+	newCaches()
 	POKEMON=make(map[string]pokemonEntry)
-	CAUGHT=make(map[string]struct{})
-	fmt.Println("Welcome to the Pokedex!")
-	scanner := bufio.NewScanner(os.Stdin)
+	CAUGHT=make(map[string]caughtRecord)
+	SPECIES=make(map[string]pokemonSpecies)
+	API = internal.NewClient(time.Second*10)
+	API.Logf = verbosef
+	loadKnownNames()
+	loadCurrentTrainer()
+	handleSignals()
 	cmdMap := createRegistry()
+	args, flagErr := parseGlobalFlags(os.Args[1:])
+	if flagErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", flagErr)
+		os.Exit(2)
+	}
+	if len(args) > 0 {
+		os.Exit(runSubcommand(cmdMap, args))
+	}
+	fmt.Println("Welcome to the Pokedex!")
+	EDITOR = newLineEditor(os.Stdin, os.Stdout, dataPath("history"), completeWord)
 	for {
-		fmt.Print("Pokedex > ")
-		if scanner.Scan() {
-			text :=scanner.Text()
+		text, readErr := EDITOR.readLine("Pokedex > ")
+		if readErr == errInterrupted {
+			continue
+		}
+		if readErr == io.EOF {
+			fmt.Println()
+			lockState()
+			commandExit(context.Background(), nil)
+		}
+		if readErr == nil {
 			userCmd := cleanInput(text)
 			if len(userCmd) < 1 {
 				fmt.Println("Unknown command")
@@ -364,27 +508,146 @@ func main() {
 				fmt.Printf("Your command was: %s\n", userCmd[0])
 				cmdObj, cmdExists := cmdMap[userCmd[0]]
 				if cmdExists {
-					if (len(userCmd) > 1) {
-						cmdObj.callback(userCmd[1])
-					} else {
-						cmdObj.callback("")
+					err := runCommand(cmdObj, userCmd[1:])
+					if err != nil {
+						fmt.Printf("Error: %v\n", err)
 					}
 				} else {
 					fmt.Println("Unknown command")
 				}
 			}
 		} else {
-			fmt.Println("ERROR READING CLI INPUT")
+			fmt.Printf("Error reading input: %v\n", readErr)
+			lockState()
+			cleanup()
+			os.Exit(1)
 		}
 	}
 }
 
-func commandExit(location string) error {
+// runCommand runs cmdObj, handling the flags every command accepts,
+// remembers any new names it came across for completion and saves the
+// trainer if it changed.
+func runCommand(cmdObj cliCommand, args []string) error {
+	lockState()
+	defer unlockState()
+	OUTPUT_JSON = false
+	timeout := COMMAND_TIMEOUT
+	var cmdArgs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--json" {
+			OUTPUT_JSON = true
+		} else if arg == "--lang" || strings.HasPrefix(arg, "--lang=") {
+			value, hasValue := strings.CutPrefix(arg, "--lang=")
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			lang, err := parseLanguage(value)
+			if err != nil {
+				return err
+			}
+			// --lang is for this command only, unlike set lang
+			defer func(saved string) { LANGUAGE = saved }(LANGUAGE)
+			LANGUAGE = lang
+		} else if arg == "--timeout" || strings.HasPrefix(arg, "--timeout=") {
+			value, hasValue := strings.CutPrefix(arg, "--timeout=")
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			d, err := parseTimeout(value)
+			if err != nil {
+				return err
+			}
+			timeout = d
+		} else {
+			cmdArgs = append(cmdArgs, arg)
+		}
+	}
+	known := knownCount()
+	ctx := startCommand(timeout)
+	err := cmdObj.callback(ctx, cmdArgs)
+	ctxErr := ctx.Err()
+	finishCommand()
+	if knownCount() != known {
+		saveKnownNames()
+	}
+	autosave()
+	if err != nil && ctxErr == context.DeadlineExceeded {
+		return fmt.Errorf("command timed out after %v", timeout)
+	}
+	if err != nil && ctxErr == context.Canceled {
+		return errCancelled
+	}
+	return err
+}
+
+// runSubcommand runs a single command given on the command line, as in
+// "pokedexcli inspect pikachu", and returns the exit status.
+func runSubcommand(cmdMap map[string]cliCommand, args []string) int {
+	switch args[0] {
+	case "completion":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: pokedexcli completion bash|zsh|fish")
+			return 2
+		}
+		script, err := completionScript(args[1], cmdMap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		fmt.Print(script)
+		return 0
+	case "__complete":
+		// called by the completion scripts with the words typed so far,
+		// the last one being the word to complete
+		if len(args) < 2 {
+			return 0
+		}
+		for _, name := range completeWord(args[1:len(args)-1], args[len(args)-1]) {
+			fmt.Println(name)
+		}
+		return 0
+	}
+	for i := range args {
+		args[i] = strings.ToLower(args[i])
+	}
+	cmdObj, cmdExists := cmdMap[args[0]]
+	if !cmdExists {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n", args[0])
+		return 2
+	}
+	err := runCommand(cmdObj, args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func commandExit(ctx context.Context, args []string) error {
 	fmt.Println("Closing the Pokedex... Goodbye!")
+	cleanup()
 	os.Exit(0)
 	return nil
 }
 
+// dataPath returns where the file name lives in the pokedex's data
+// directory, $POKEDEX_HOME or ~/.pokedexcli.
+func dataPath(name string) string {
+	dir := os.Getenv("POKEDEX_HOME")
+	if dir == "" {
+		home, homeErr := os.UserHomeDir()
+		if homeErr != nil {
+			home = "."
+		}
+		dir = filepath.Join(home, ".pokedexcli")
+	}
+	return filepath.Join(dir, name)
+}
+
 func cleanInput(text string) []string {
 	words := strings.Fields(text)
 	for i:=range words {
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"pokedexcli/internal"
+	"strconv"
+)
+
+type namedResource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type region struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Names     []localizedName `json:"names"`
+	Locations []namedResource `json:"locations"`
+	Pokedexes []namedResource `json:"pokedexes"`
+}
+
+type location struct {
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Names  []localizedName `json:"names"`
+	Region namedResource   `json:"region"`
+	Areas  []namedResource `json:"areas"`
+}
+
+// mapPage is where map and mapb are in the list of location areas. Without
+// a region we follow the next and previous links the API gives us; with
+// one we page through the areas of the region's locations ourselves.
+type mapPage struct {
+	region   string
+	size     int
+	offset   int
+	count    int
+	next     string
+	previous string
+	shown    bool // false until the first page has been shown
+}
+
+func (page mapPage) number() int {
+	return page.offset/page.size + 1
+}
+
+func (page mapPage) pages() int {
+	return max((page.count+page.size-1)/page.size, 1)
+}
+
+func (page mapPage) firstURL() string {
+	return fmt.Sprintf("https://pokeapi.co/api/v2/location-area/?offset=%v&limit=%v", page.offset, page.size)
+}
+
+// mapFlags applies map's --region, --size and --page to page. It reports
+// whether they moved it, in which case map shows where they moved it to
+// instead of the next page.
+func mapFlags(page *mapPage, flags flagSet) (bool, error) {
+	moved := false
+	if flags.has("region") {
+		page.region = flags.get("region")
+		if page.region == "all" {
+			page.region = ""
+		}
+		page.offset = 0
+		moved = true
+	}
+	if flags.has("size") {
+		size, err := strconv.Atoi(flags.get("size"))
+		if err != nil || size < 1 {
+			return false, fmt.Errorf("invalid page size %q", flags.get("size"))
+		}
+		// stay on the page holding the first name we're showing
+		page.offset = page.offset / size * size
+		page.size = size
+		moved = true
+	}
+	if flags.has("page") {
+		n, err := strconv.Atoi(flags.get("page"))
+		if err != nil || n < 1 {
+			return false, fmt.Errorf("invalid page %q", flags.get("page"))
+		}
+		page.offset = (n - 1) * page.size
+		moved = true
+	}
+	return moved || !page.shown, nil
+}
+
+// showMapPage fetches and prints the page at page.offset, or at link when
+// one is given, and fills in what the API told us about the list. It
+// returns false when there was nothing to show.
+func showMapPage(ctx context.Context, page *mapPage, link string) (bool, error) {
+	var names []string
+	if page.region == "" {
+		if link == "" {
+			link = page.firstURL()
+		}
+		la, err := getList(ctx, link)
+		if err != nil {
+			return false, err
+		}
+		if offset, err := strconv.Atoi(queryParam(link, "offset")); err == nil {
+			page.offset = offset
+		}
+		page.count, page.next, page.previous = la.Count, la.Next, la.Previous
+		for _, obj := range la.Results {
+			names = append(names, obj.Name)
+		}
+	} else {
+		reg, lookup, err := getRegion(ctx, page.region)
+		if err != nil {
+			return false, err
+		}
+		if lookup == internal.KnownMissing {
+			fmt.Printf("Region %s not found\n", page.region)
+			return false, nil
+		}
+		KNOWN_REGIONS[reg.Name] = struct{}{}
+		// pages are of areas, so all of the region's locations are needed
+		// to count them
+		areas, err := regionAreas(ctx, reg.Locations)
+		if err != nil {
+			return false, err
+		}
+		page.count = len(areas)
+		if page.offset < page.count {
+			names = areas[page.offset:min(page.offset+page.size, page.count)]
+		}
+	}
+	if page.offset > 0 && page.offset >= page.count {
+		fmt.Printf("There are only %d pages\n", page.pages())
+		return false, nil
+	}
+
+	var mapList string
+	for _, name := range names {
+		mapList = mapList + fmt.Sprintf("%s\n", name)
+		KNOWN_AREAS[name] = struct{}{}
+	}
+	fmt.Print(mapList)
+	if page.region != "" {
+		fmt.Printf("page %d of %d (%s)\n", page.number(), page.pages(), page.region)
+	} else {
+		fmt.Printf("page %d of %d\n", page.number(), page.pages())
+	}
+	page.shown = true
+	return true, nil
+}
+
+// regionAreas lists the areas of locations, fetching a few locations at a
+// time.
+func regionAreas(ctx context.Context, locations []namedResource) ([]string, error) {
+	areas := make([][]namedResource, len(locations))
+	errs := make([]error, len(locations))
+	lookupEach(len(locations), func(i int) {
+		place, _, err := getLocation(ctx, locations[i].Name)
+		areas[i], errs[i] = place.Areas, err
+	})
+	var names []string
+	for i, loc := range locations {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		KNOWN_LOCATIONS[loc.Name] = struct{}{}
+		for _, area := range areas[i] {
+			names = append(names, area.Name)
+		}
+	}
+	return names, nil
+}
+
+// getList fetches a page of one of the API's resource lists.
+func getList(ctx context.Context, link string) (locationArea, error) {
+	list, lookup, err := MAP_CACHE.Fetch(ctx, link, AREA_TTL, AREA_STALE, func(ctx context.Context) (locationArea, error) {
+		var list locationArea
+		return list, API.GetJSON(ctx, link, &list)
+	})
+	noteStale(lookup, "resource list")
+	return list, err
+}
+
+func listRegions(ctx context.Context, args []string) error {
+	list, err := getList(ctx, "https://pokeapi.co/api/v2/region/?limit=100")
+	if err != nil {
+		return err
+	}
+	for _, reg := range list.Results {
+		fmt.Println(reg.Name)
+		KNOWN_REGIONS[reg.Name] = struct{}{}
+	}
+	return nil
+}
+
+func listLocations(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		fmt.Println("Usage: locations <region>")
+		return nil
+	}
+	reg, lookup, err := getRegion(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if lookup == internal.KnownMissing {
+		fmt.Printf("Region %s not found\n", args[0])
+		return nil
+	}
+	KNOWN_REGIONS[reg.Name] = struct{}{}
+	fmt.Printf("Locations in %s:\n", pickName(reg.Names, reg.Name))
+	for _, loc := range reg.Locations {
+		fmt.Printf(" - %s\n", loc.Name)
+		KNOWN_LOCATIONS[loc.Name] = struct{}{}
+	}
+	return nil
+}
+
+func listAreas(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		fmt.Println("Usage: areas <location>")
+		return nil
+	}
+	place, lookup, err := getLocation(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if lookup == internal.KnownMissing {
+		fmt.Printf("Location %s not found\n", args[0])
+		return nil
+	}
+	KNOWN_LOCATIONS[place.Name] = struct{}{}
+	if place.Region.Name != "" {
+		KNOWN_REGIONS[place.Region.Name] = struct{}{}
+		fmt.Printf("Areas in %s > %s:\n", localName(ctx, "region", place.Region.Name), pickName(place.Names, place.Name))
+	} else {
+		fmt.Printf("Areas in %s:\n", pickName(place.Names, place.Name))
+	}
+	var areas []string
+	for _, area := range place.Areas {
+		areas = append(areas, area.Name)
+		KNOWN_AREAS[area.Name] = struct{}{}
+	}
+	for _, name := range localNames(ctx, "location-area", areas) {
+		fmt.Printf(" - %s\n", name)
+	}
+	return nil
+}
+
+// areaPath names the region and location an area is in, as in
+// "sinnoh > canalave-city". It settles for less when the API doesn't
+// know, or can't be asked.
+func areaPath(ctx context.Context, lal locationAreaLocation) string {
+	if lal.Location.Name == "" {
+		return "an unknown location"
+	}
+	place, _, err := getLocation(ctx, lal.Location.Name)
+	if err != nil {
+		verbosef("could not look up location %s: %v", lal.Location.Name, err)
+	}
+	if place.Region.Name == "" {
+		return pickName(place.Names, lal.Location.Name)
+	}
+	return localName(ctx, "region", place.Region.Name) + " > " + pickName(place.Names, lal.Location.Name)
+}
+
+func getRegion(ctx context.Context, name string) (region, internal.LookupResult, error) {
+	reg, lookup, err := REGION_CACHE.Fetch(ctx, name, AREA_TTL, AREA_STALE, func(ctx context.Context) (region, error) {
+		var reg region
+		return reg, API.GetJSON(ctx, fmt.Sprintf("https://pokeapi.co/api/v2/region/%s", name), &reg)
+	})
+	noteStale(lookup, name)
+	return reg, lookup, err
+}
+
+func getLocation(ctx context.Context, name string) (location, internal.LookupResult, error) {
+	place, lookup, err := LOCATION_CACHE.Fetch(ctx, name, AREA_TTL, AREA_STALE, func(ctx context.Context) (location, error) {
+		var place location
+		return place, API.GetJSON(ctx, fmt.Sprintf("https://pokeapi.co/api/v2/location/%s", name), &place)
+	})
+	noteStale(lookup, name)
+	return place, lookup, err
+}
+
+func queryParam(link string, name string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return parsed.Query().Get(name)
+}
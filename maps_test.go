@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"pokedexcli/internal"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMapFlags(t *testing.T) {
+	cases := []struct {
+		name      string
+		page      mapPage
+		args      []string
+		expected  mapPage
+		jump      bool
+		expectErr bool
+	}{
+		{
+			name:     "first map shows the first page",
+			page:     mapPage{size: 20},
+			expected: mapPage{size: 20},
+			jump:     true,
+		},
+		{
+			name:     "no flags moves on",
+			page:     mapPage{size: 20, offset: 40, shown: true},
+			expected: mapPage{size: 20, offset: 40, shown: true},
+		},
+		{
+			name:     "jump to a page",
+			page:     mapPage{size: 20, shown: true},
+			args:     []string{"--page", "3"},
+			expected: mapPage{size: 20, offset: 40, shown: true},
+			jump:     true,
+		},
+		{
+			name:     "a new size keeps the first name in view",
+			page:     mapPage{size: 20, offset: 60, shown: true},
+			args:     []string{"--size", "50"},
+			expected: mapPage{size: 50, offset: 50, shown: true},
+			jump:     true,
+		},
+		{
+			name:     "page counts in the new size",
+			page:     mapPage{size: 20, shown: true},
+			args:     []string{"--size=50", "--page=2"},
+			expected: mapPage{size: 50, offset: 50, shown: true},
+			jump:     true,
+		},
+		{
+			name:     "a region starts from its first page",
+			page:     mapPage{size: 20, offset: 60, shown: true},
+			args:     []string{"--region", "sinnoh"},
+			expected: mapPage{region: "sinnoh", size: 20, shown: true},
+			jump:     true,
+		},
+		{
+			name:     "all leaves the region",
+			page:     mapPage{region: "sinnoh", size: 20, offset: 20, shown: true},
+			args:     []string{"--region", "all"},
+			expected: mapPage{size: 20, shown: true},
+			jump:     true,
+		},
+		{
+			name:      "bad page",
+			page:      mapPage{size: 20},
+			args:      []string{"--page", "0"},
+			expectErr: true,
+		},
+		{
+			name:      "bad size",
+			page:      mapPage{size: 20},
+			args:      []string{"--size", "lots"},
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, flags := parseArgs(c.args)
+			page := c.page
+			jump, err := mapFlags(&page, flags)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil || jump != c.jump || page != c.expected {
+				t.Errorf("expected %+v, %v, got %+v, %v, %v", c.expected, c.jump, page, jump, err)
+			}
+		})
+	}
+}
+
+func TestMapPageNumbers(t *testing.T) {
+	page := mapPage{size: 20, offset: 40, count: 1089}
+	if page.number() != 3 || page.pages() != 55 {
+		t.Errorf("expected page 3 of 55, got %v of %v", page.number(), page.pages())
+	}
+	if offset := queryParam("https://pokeapi.co/api/v2/location-area/?offset=40&limit=20", "offset"); offset != "40" {
+		t.Errorf("expected offset 40, got %q", offset)
+	}
+}
+
+// apiTransport answers requests with the JSON for their URL, or a 404.
+type apiTransport map[string]string
+
+func (responses apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, exists := responses[req.URL.String()]
+	if !exists {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("Not Found")), Request: req}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+var testMapAPI = apiTransport{
+	"https://pokeapi.co/api/v2/region/?limit=100":      `{"count": 2, "results": [{"name": "kanto"}, {"name": "sinnoh"}]}`,
+	"https://pokeapi.co/api/v2/region/sinnoh":          `{"name": "sinnoh", "locations": [{"name": "canalave-city"}, {"name": "eterna-forest"}]}`,
+	"https://pokeapi.co/api/v2/location/canalave-city": `{"name": "canalave-city", "region": {"name": "sinnoh"}, "areas": [{"name": "canalave-city-area"}]}`,
+	"https://pokeapi.co/api/v2/location/mystery-zone":  `{"name": "mystery-zone", "areas": [{"name": "mystery-zone-area"}]}`,
+}
+
+func useTestMapAPI(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	API.HTTPClient = &http.Client{Transport: testMapAPI}
+	API.MaxAttempts = 1
+	newCaches()
+	savedLanguage := LANGUAGE
+	LANGUAGE = ""
+	t.Cleanup(func() { LANGUAGE = savedLanguage })
+}
+
+// captureStdout returns what run prints.
+func captureStdout(t *testing.T, run func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	run()
+	w.Close()
+	return <-output
+}
+
+func TestMapListings(t *testing.T) {
+	useTestMapAPI(t)
+	cases := []struct {
+		name     string
+		command  func(context.Context, []string) error
+		args     []string
+		expected string
+	}{
+		{
+			name:     "regions",
+			command:  listRegions,
+			expected: "kanto\nsinnoh\n",
+		},
+		{
+			name:     "locations in a region",
+			command:  listLocations,
+			args:     []string{"sinnoh"},
+			expected: "Locations in sinnoh:\n - canalave-city\n - eterna-forest\n",
+		},
+		{
+			name:     "locations in an unknown region",
+			command:  listLocations,
+			args:     []string{"nowhere"},
+			expected: "Region nowhere not found\n",
+		},
+		{
+			name:     "locations without a region",
+			command:  listLocations,
+			expected: "Usage: locations <region>\n",
+		},
+		{
+			name:     "areas in a location with a region",
+			command:  listAreas,
+			args:     []string{"canalave-city"},
+			expected: "Areas in sinnoh > canalave-city:\n - canalave-city-area\n",
+		},
+		{
+			name:     "areas in a location without a region",
+			command:  listAreas,
+			args:     []string{"mystery-zone"},
+			expected: "Areas in mystery-zone:\n - mystery-zone-area\n",
+		},
+		{
+			name:     "areas in an unknown location",
+			command:  listAreas,
+			args:     []string{"nowhere"},
+			expected: "Location nowhere not found\n",
+		},
+		{
+			name:     "areas without a location",
+			command:  listAreas,
+			expected: "Usage: areas <location>\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var err error
+			output := captureStdout(t, func() {
+				err = c.command(context.Background(), c.args)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if output != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestAreaPath(t *testing.T) {
+	useTestMapAPI(t)
+	cases := []struct {
+		name     string
+		location string
+		expected string
+	}{
+		{name: "with a region", location: "canalave-city", expected: "sinnoh > canalave-city"},
+		{name: "without a region", location: "mystery-zone", expected: "mystery-zone"},
+		{name: "unknown location", location: "nowhere", expected: "nowhere"},
+		{name: "no location", expected: "an unknown location"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var lal locationAreaLocation
+			lal.Location.Name = c.location
+			if path := areaPath(context.Background(), lal); path != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, path)
+			}
+		})
+	}
+}
+
+func TestRegionMapPages(t *testing.T) {
+	useTestMapAPI(t)
+	API.HTTPClient = &http.Client{Transport: apiTransport{
+		"https://pokeapi.co/api/v2/region/sinnoh":          `{"name": "sinnoh", "locations": [{"name": "mt-coronet"}, {"name": "canalave-city"}]}`,
+		"https://pokeapi.co/api/v2/location/mt-coronet":    `{"name": "mt-coronet", "areas": [{"name": "mt-coronet-1f-route-207"}, {"name": "mt-coronet-2f"}, {"name": "mt-coronet-3f"}]}`,
+		"https://pokeapi.co/api/v2/location/canalave-city": `{"name": "canalave-city", "areas": [{"name": "canalave-city-area"}]}`,
+	}}
+	cases := []struct {
+		offset   int
+		shown    bool
+		expected string
+	}{
+		{offset: 0, shown: true, expected: "mt-coronet-1f-route-207\nmt-coronet-2f\npage 1 of 2 (sinnoh)\n"},
+		{offset: 2, shown: true, expected: "mt-coronet-3f\ncanalave-city-area\npage 2 of 2 (sinnoh)\n"},
+		{offset: 4, expected: "There are only 2 pages\n"},
+	}
+	for _, c := range cases {
+		page := mapPage{region: "sinnoh", size: 2, offset: c.offset}
+		var shown bool
+		var err error
+		output := captureStdout(t, func() {
+			shown, err = showMapPage(context.Background(), &page, "")
+		})
+		if err != nil || shown != c.shown || output != c.expected {
+			t.Errorf("offset %d: expected %q, %v, got %q, %v, %v", c.offset, c.expected, c.shown, output, shown, err)
+		}
+		if page.count != 4 {
+			t.Errorf("offset %d: expected 4 areas, got %d", c.offset, page.count)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package main
 import (
+	"pokedexcli/internal"
 	"testing"
+	"time"
 )
 
 func TestCleanInput(t *testing.T) {
@@ -36,4 +38,67 @@ func TestCleanInput(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+func TestParseArgs(t *testing.T) {
+	words, flags := parseArgs([]string{"pikachu", "--section", "moves", "--shiny", "--gen=v", "--filter", "type=fire", "--filter", "gen=1"}, "shiny")
+	if len(words) != 1 || words[0] != "pikachu" {
+		t.Errorf("Words don't match, EXPECTED: [pikachu]\tACTUAL: %v", words)
+	}
+	if flags.get("section") != "moves" {
+		t.Errorf("Expected section moves, got %q", flags.get("section"))
+	}
+	if !flags.has("shiny") || flags.get("shiny") != "" {
+		t.Errorf("Expected bool flag shiny, got %v", flags)
+	}
+	if flags.get("gen") != "v" {
+		t.Errorf("Expected gen v, got %q", flags.get("gen"))
+	}
+	if filters := flags.all("filter"); len(filters) != 2 || filters[1] != "gen=1" {
+		t.Errorf("Expected both filters, got %v", filters)
+	}
+}
+
+func TestCleanFlavorText(t *testing.T) {
+	actual := cleanFlavorText("A strange seed was\nplanted on its\fback at birth.")
+	expected := "A strange seed was planted on its back at birth."
+	if actual != expected {
+		t.Errorf("EXPECTED: %q\tACTUAL: %q", expected, actual)
+	}
+}
+
+func TestResourceID(t *testing.T) {
+	if id := resourceID("https://pokeapi.co/api/v2/version-group/20/"); id != 20 {
+		t.Errorf("Expected 20, got %v", id)
+	}
+	if id := resourceID("https://pokeapi.co/api/v2/"); id != 0 {
+		t.Errorf("Expected 0, got %v", id)
+	}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	API = internal.NewClient(time.Second)
+	defer func(timeout time.Duration) { COMMAND_TIMEOUT = timeout }(COMMAND_TIMEOUT)
+
+	defer func() { VERBOSE = false }()
+
+	rest, err := parseGlobalFlags([]string{"--timeout", "5s", "--verbose", "--request-timeout=2s", "inspect", "--section", "moves"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(rest) != 3 || rest[0] != "inspect" {
+		t.Errorf("Expected the command to be left over, got %v", rest)
+	}
+	if COMMAND_TIMEOUT != 5*time.Second || API.RequestTimeout != 2*time.Second {
+		t.Errorf("Expected timeouts 5s and 2s, got %v and %v", COMMAND_TIMEOUT, API.RequestTimeout)
+	}
+	if !VERBOSE {
+		t.Errorf("Expected a bare --verbose to turn verbose mode on")
+	}
+
+	for _, bad := range [][]string{{"--timeout", "soon"}, {"--timeout"}, {"--nope", "1"}} {
+		_, err := parseGlobalFlags(bad)
+		if err == nil {
+			t.Errorf("Expected an error for %v", bad)
+		}
+	}
+}
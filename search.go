@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nameIndex holds every pokemon and location area name, for search and
+// for suggesting names when one isn't found. It is kept on disk since the
+// lists only change when new games come out.
+type nameIndex struct {
+	Fetched time.Time `json:"fetched"`
+	Pokemon []string  `json:"pokemon"`
+	Areas   []string  `json:"areas"`
+}
+
+var NAME_INDEX *nameIndex
+
+// INDEX_TTL is how old the index on disk may get before we fetch it again.
+const INDEX_TTL = time.Hour * 24 * 7
+
+// getNameIndex returns the name index, reading it from disk or fetching it
+// the first time it's needed. If it can't be fetched an out of date index
+// is better than none.
+func getNameIndex(ctx context.Context) (*nameIndex, error) {
+	if NAME_INDEX != nil && time.Since(NAME_INDEX.Fetched) < INDEX_TTL {
+		return NAME_INDEX, nil
+	}
+	if NAME_INDEX == nil {
+		var index nameIndex
+		data, err := os.ReadFile(dataPath("index.json"))
+		if err == nil && json.Unmarshal(data, &index) == nil {
+			NAME_INDEX = &index
+		}
+		if NAME_INDEX != nil && time.Since(NAME_INDEX.Fetched) < INDEX_TTL {
+			return NAME_INDEX, nil
+		}
+	}
+	index, err := fetchNameIndex(ctx)
+	if err != nil {
+		if NAME_INDEX != nil {
+			verbosef("could not refresh the name index: %v", err)
+			return NAME_INDEX, nil
+		}
+		return nil, err
+	}
+	NAME_INDEX = index
+	saveNameIndex(index)
+	return index, nil
+}
+
+func fetchNameIndex(ctx context.Context) (*nameIndex, error) {
+	index := &nameIndex{Fetched: time.Now()}
+	lists := []struct {
+		url   string
+		names *[]string
+	}{
+		{"https://pokeapi.co/api/v2/pokemon/?limit=100000", &index.Pokemon},
+		{"https://pokeapi.co/api/v2/location-area/?limit=100000", &index.Areas},
+	}
+	for _, list := range lists {
+		var la locationArea
+		err := API.GetJSON(ctx, list.url, &la)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range la.Results {
+			*list.names = append(*list.names, obj.Name)
+		}
+	}
+	return index, nil
+}
+
+// saveNameIndex writes the index to disk. This is best effort, like the
+// history.
+func saveNameIndex(index *nameIndex) {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return
+	}
+	path := dataPath("index.json")
+	os.MkdirAll(filepath.Dir(path), 0700)
+	os.WriteFile(path, data, 0600)
+}
+
+type searchResult struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func search(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args, "pokemon", "areas")
+	if len(words) < 1 {
+		fmt.Println("Usage: search <query> [--pokemon|--areas] [--limit <n>]")
+		return nil
+	}
+	limit := 10
+	if flags.has("limit") {
+		n, err := strconv.Atoi(flags.get("limit"))
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid limit %q", flags.get("limit"))
+		}
+		limit = n
+	}
+	index, err := getNameIndex(ctx)
+	if err != nil {
+		return err
+	}
+	query := strings.Join(words, "-")
+	var results []searchResult
+	var scores []int
+	addMatches := func(kind string, names []string) {
+		for _, name := range names {
+			if score, ok := fuzzyScore(query, name); ok {
+				results = append(results, searchResult{Kind: kind, Name: name})
+				scores = append(scores, score)
+			}
+		}
+	}
+	if !flags.has("areas") {
+		addMatches("pokemon", index.Pokemon)
+	}
+	if !flags.has("pokemon") {
+		addMatches("area", index.Areas)
+	}
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] < scores[order[b]]
+	})
+	ranked := make([]searchResult, 0, min(limit, len(order)))
+	for _, i := range order[:min(limit, len(order))] {
+		ranked = append(ranked, results[i])
+	}
+
+	if OUTPUT_JSON {
+		return printJSON(ranked)
+	}
+	if len(ranked) == 0 {
+		fmt.Printf("Nothing matches %s\n", query)
+		return nil
+	}
+	for _, result := range ranked {
+		fmt.Printf(" - %s (%s)\n", result.Name, result.Kind)
+	}
+	return nil
+}
+
+// fuzzyScore rates how well name matches query, lower being better. Exact
+// matches come first, then names starting with the query, then names
+// containing it, then names a few typos away.
+func fuzzyScore(query string, name string) (int, bool) {
+	switch {
+	case name == query:
+		return 0, true
+	case strings.HasPrefix(name, query):
+		return 1000 + len(name), true
+	case strings.Contains(name, query):
+		return 2000 + len(name), true
+	}
+	// compare against the start of the name too, so "charmnder" finds
+	// charmander-gmax as well as charmander
+	typos := maxTypos(query)
+	d := editDistance(query, name)
+	for n := max(len(query)-typos, 1); n <= min(len(query)+typos, len(name)-1); n++ {
+		d = min(d, editDistance(query, name[:n])+1)
+	}
+	if d > typos {
+		return 0, false
+	}
+	return 3000 + d*100 + len(name), true
+}
+
+// maxTypos is how many edits a name may be from the query and still match.
+func maxTypos(query string) int {
+	return max(1, len(query)/4)
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// neighbouring letters it takes to turn a into b.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// suggestName returns the closest name to a name that wasn't found, or ""
+// if nothing is close. Suggestions are a nicety, so a missing index just
+// means there aren't any.
+func suggestName(ctx context.Context, name string, pokemon bool) string {
+	index, err := getNameIndex(ctx)
+	if err != nil {
+		verbosef("no suggestions: %v", err)
+		return ""
+	}
+	names := index.Areas
+	if pokemon {
+		names = index.Pokemon
+	}
+	return closestName(name, names)
+}
+
+// closestName returns the name in names fewest typos from name, or "" if
+// none are close enough.
+func closestName(name string, names []string) string {
+	best, bestDistance := "", maxTypos(name)+1
+	for _, candidate := range names {
+		if d := editDistance(name, candidate); d < bestDistance && candidate != name {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// didYouMean is suggestName as the end of a not found message.
+func didYouMean(ctx context.Context, name string, pokemon bool) string {
+	suggestion := suggestName(ctx, name, pokemon)
+	if suggestion == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", suggestion)
+}
+
+// didYouMeanCaught is didYouMean for commands that only know the pokemon
+// we've thrown a ball at, so suggestions come from those.
+func didYouMeanCaught(name string) string {
+	var names []string
+	for caught := range POKEMON {
+		names = append(names, caught)
+	}
+	sort.Strings(names)
+	suggestion := closestName(name, names)
+	if suggestion == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", suggestion)
+}
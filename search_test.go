@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"charmander", "charmander", 0},
+		{"charmnder", "charmander", 1},
+		{"chramander", "charmander", 1},
+		{"pikachu", "pikachoo", 2},
+		{"", "eevee", 5},
+	}
+	for _, c := range cases {
+		if d := editDistance(c.a, c.b); d != c.expected {
+			t.Errorf("%s to %s: expected %v, got %v", c.a, c.b, c.expected, d)
+		}
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	names := []string{"charmeleon", "charmander-gmax", "charmander", "pikachu", "raichu"}
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "charmander", expected: []string{"charmander", "charmander-gmax"}},
+		{query: "charm", expected: []string{"charmeleon", "charmander", "charmander-gmax"}},
+		{query: "chu", expected: []string{"raichu", "pikachu"}},
+		{query: "charmnder", expected: []string{"charmander", "charmander-gmax"}},
+		{query: "bulbasaur"},
+	}
+	for _, c := range cases {
+		var matches []string
+		var scores []int
+		for _, name := range names {
+			if score, ok := fuzzyScore(c.query, name); ok {
+				i := 0
+				for i < len(scores) && scores[i] <= score {
+					i++
+				}
+				matches = append(matches[:i], append([]string{name}, matches[i:]...)...)
+				scores = append(scores[:i], append([]int{score}, scores[i:]...)...)
+			}
+		}
+		if len(matches) != len(c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.query, c.expected, matches)
+			continue
+		}
+		for i := range matches {
+			if matches[i] != c.expected[i] {
+				t.Errorf("%s: expected %v, got %v", c.query, c.expected, matches)
+				break
+			}
+		}
+	}
+}
+
+func TestSuggestName(t *testing.T) {
+	NAME_INDEX = &nameIndex{Fetched: time.Now(), Pokemon: []string{"charmander", "charmeleon", "pikachu"}, Areas: []string{"canalave-city-area"}}
+	defer func() { NAME_INDEX = nil }()
+	if s := suggestName(context.Background(), "charmandr", true); s != "charmander" {
+		t.Errorf("expected charmander, got %q", s)
+	}
+	if s := suggestName(context.Background(), "canalave-cty-area", false); s != "canalave-city-area" {
+		t.Errorf("expected canalave-city-area, got %q", s)
+	}
+	if s := suggestName(context.Background(), "mewtwo", true); s != "" {
+		t.Errorf("expected no suggestion, got %q", s)
+	}
+}
+
+func TestDidYouMeanCaught(t *testing.T) {
+	defer func(saved map[string]pokemonEntry) { POKEMON = saved }(POKEMON)
+	POKEMON = map[string]pokemonEntry{"pikachu": {}, "charmander": {}}
+	if s := didYouMeanCaught("pikachi"); s != ", did you mean pikachu?" {
+		t.Errorf("expected pikachu to be suggested, got %q", s)
+	}
+	if s := didYouMeanCaught("charmanderr"); s != ", did you mean charmander?" {
+		t.Errorf("expected charmander to be suggested, got %q", s)
+	}
+	if s := didYouMeanCaught("bulbasaur"); s != "" {
+		t.Errorf("expected no suggestion for a pokemon we haven't met, got %q", s)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// COMMAND_TIMEOUT is how long a whole command may run, zero for no limit.
+// The limit on each request lives in API.RequestTimeout.
+var COMMAND_TIMEOUT = 60 * time.Second
+
+// VERBOSE shows what the API client is doing behind the scenes, such as
+// retries.
+var VERBOSE bool
+
+type setting struct {
+	name        string
+	description string
+	// boolean settings are turned on by a bare --name on the command line
+	boolean bool
+	get     func() string
+	set     func(value string) error
+}
+
+func createSettings() []setting {
+	return []setting{
+		{
+			name:        "verbose",
+			description: "Show retries and other API client activity, on or off",
+			boolean:     true,
+			get:         func() string { return onOff(VERBOSE) },
+			set: func(value string) error {
+				on, err := parseOnOff(value)
+				if err == nil {
+					VERBOSE = on
+				}
+				return err
+			},
+		},
+		{
+			name:        "timeout",
+			description: "How long a command may run before it is cancelled, 0 for no limit",
+			get:         func() string { return COMMAND_TIMEOUT.String() },
+			set: func(value string) error {
+				d, err := parseTimeout(value)
+				if err == nil {
+					COMMAND_TIMEOUT = d
+				}
+				return err
+			},
+		},
+		{
+			name:        "lang",
+			description: "Language to show pokemon, place, type and move names in, such as ja, fr or de, off for the API's names",
+			get: func() string {
+				if LANGUAGE == "" {
+					return "off"
+				}
+				return LANGUAGE
+			},
+			set: func(value string) error {
+				lang, err := parseLanguage(value)
+				if err == nil {
+					LANGUAGE = lang
+				}
+				return err
+			},
+		},
+		{
+			name:        "game",
+			description: "Game version, such as heartgold, to limit encounters, moves, sprites and catching to, off for every game",
+			get: func() string {
+				if GAME == "" {
+					return "off"
+				}
+				return GAME
+			},
+			set: func(value string) error {
+				value = strings.ToLower(value)
+				if value == "off" {
+					value = ""
+				}
+				GAME = value
+				return nil
+			},
+		},
+		{
+			name:        "rps",
+			description: "Requests per second allowed to pokeapi.co, 0 for no limit",
+			get: func() string {
+				rate, _ := API.Limiter.Rate()
+				return strconv.FormatFloat(rate, 'g', -1, 64)
+			},
+			set: func(value string) error {
+				rate, err := strconv.ParseFloat(value, 64)
+				if err != nil || rate < 0 {
+					return fmt.Errorf("invalid rate %q", value)
+				}
+				_, burst := API.Limiter.Rate()
+				API.Limiter.SetRate(rate, burst)
+				return nil
+			},
+		},
+		{
+			name:        "burst",
+			description: "Requests that may go out at once before rps kicks in",
+			get: func() string {
+				_, burst := API.Limiter.Rate()
+				return strconv.Itoa(burst)
+			},
+			set: func(value string) error {
+				burst, err := strconv.Atoi(value)
+				if err != nil || burst < 1 {
+					return fmt.Errorf("invalid burst %q, expected a whole number of at least 1", value)
+				}
+				rate, _ := API.Limiter.Rate()
+				API.Limiter.SetRate(rate, burst)
+				return nil
+			},
+		},
+		{
+			name:        "max-conns",
+			description: "Requests that may be in flight at the same time, 0 for no limit",
+			get:         func() string { return strconv.Itoa(API.MaxConns()) },
+			set: func(value string) error {
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return fmt.Errorf("invalid connection limit %q", value)
+				}
+				API.SetMaxConns(n)
+				return nil
+			},
+		},
+		{
+			name:        "request-timeout",
+			description: "How long a single request to pokeapi.co may take, 0 for no limit",
+			get:         func() string { return API.RequestTimeout.String() },
+			set: func(value string) error {
+				d, err := parseTimeout(value)
+				if err == nil {
+					API.RequestTimeout = d
+				}
+				return err
+			},
+		},
+	}
+}
+
+func findSetting(name string) (setting, bool) {
+	for _, s := range createSettings() {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func parseTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q, try something like 5s or 1m", value)
+	}
+	return d, nil
+}
+
+func parseOnOff(value string) (bool, error) {
+	switch value {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off, got %q", value)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// verbosef prints a line to stderr when verbose mode is on.
+func verbosef(format string, args ...any) {
+	if VERBOSE {
+		fmt.Fprintf(os.Stderr, "[verbose] "+format+"\n", args...)
+	}
+}
+
+// parseGlobalFlags applies the settings given as flags before the command
+// name, as in "pokedexcli --timeout 5s map", and returns the rest of args.
+func parseGlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		name, value, hasValue := strings.Cut(args[0][2:], "=")
+		s, exists := findSetting(name)
+		if !exists {
+			return nil, fmt.Errorf("unknown flag --%s", name)
+		}
+		args = args[1:]
+		if !hasValue {
+			if s.boolean {
+				value = "on"
+			} else if len(args) > 0 {
+				value = args[0]
+				args = args[1:]
+			} else {
+				return nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+		}
+		err := s.set(value)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %v", name, err)
+		}
+	}
+	return args, nil
+}
+
+func commandSet(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		settings := createSettings()
+		if OUTPUT_JSON {
+			values := make(map[string]string)
+			for _, s := range settings {
+				values[s.name] = s.get()
+			}
+			return printJSON(values)
+		}
+		for _, s := range settings {
+			fmt.Printf("%s = %s\t%s\n", s.name, s.get(), s.description)
+		}
+		return nil
+	}
+	s, exists := findSetting(args[0])
+	if !exists {
+		return fmt.Errorf("unknown setting %q, type set to list them", args[0])
+	}
+	if len(args) > 1 {
+		old := s.get()
+		err := s.set(args[1])
+		if err != nil {
+			return err
+		}
+		// games can only be checked by asking the API about them
+		if s.name == "game" {
+			_, err = activeGame(ctx)
+			if err != nil {
+				s.set(old)
+				return err
+			}
+		}
+		TRAINER_SETTINGS[s.name] = s.get()
+		TRAINER_CHANGED = true
+	}
+	fmt.Printf("%s = %s\n", s.name, s.get())
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var EDITOR *lineEditor
+
+var errCancelled = errors.New("command cancelled")
+
+var cmdMu sync.Mutex
+var cmdCancel context.CancelFunc
+
+// stateLock is held while a command runs and while the pokedex shuts down,
+// so nothing is saved while a command is halfway through changing it. It's
+// a channel so shutdown can give up waiting.
+var stateLock = make(chan struct{}, 1)
+
+// SHUTDOWN_WAIT is how long shutdown waits for a cancelled command to
+// return before exiting without saving.
+var SHUTDOWN_WAIT = 5 * time.Second
+
+func lockState() {
+	stateLock <- struct{}{}
+}
+
+func unlockState() {
+	<-stateLock
+}
+
+func lockStateWithin(wait time.Duration) bool {
+	select {
+	case stateLock <- struct{}{}:
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
+
+// startCommand returns the context for the next command, cancelled when
+// the user interrupts it or after timeout if that isn't zero.
+func startCommand(timeout time.Duration) context.Context {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	cmdMu.Lock()
+	cmdCancel = cancel
+	cmdMu.Unlock()
+	return ctx
+}
+
+func finishCommand() {
+	cmdMu.Lock()
+	cancel := cmdCancel
+	cmdCancel = nil
+	cmdMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// handleSignals makes the first SIGINT cancel the running command. A second
+// SIGINT before it returns, a SIGINT with no command running, SIGTERM, or
+// SIGHUP when the terminal is closed shuts the pokedex down. At the line
+// editor's prompt Ctrl-C is read as a key instead and just abandons the
+// line.
+func handleSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			cmdMu.Lock()
+			cancel := cmdCancel
+			cmdCancel = nil
+			cmdMu.Unlock()
+			if sig == os.Interrupt && cancel != nil {
+				fmt.Println("^C")
+				cancel()
+				continue
+			}
+			fmt.Println("\nClosing the Pokedex... Goodbye!")
+			shutdown(cancel, SHUTDOWN_WAIT)
+			switch sig {
+			case os.Interrupt:
+				os.Exit(130)
+			case syscall.SIGHUP:
+				os.Exit(129)
+			}
+			os.Exit(143)
+		}
+	}()
+}
+
+// shutdown cancels the running command, if any, and cleans up once it has
+// returned. If it doesn't return within wait only the terminal is put
+// back, since saving could catch the game state halfway through a change.
+// It reports whether everything was saved.
+func shutdown(cancel context.CancelFunc, wait time.Duration) bool {
+	if cancel != nil {
+		cancel()
+	}
+	if !lockStateWithin(wait) {
+		if EDITOR != nil {
+			EDITOR.restoreTerminal()
+		}
+		fmt.Fprintln(os.Stderr, "The running command didn't stop, exiting without saving")
+		return false
+	}
+	defer unlockState()
+	cleanup()
+	return true
+}
+
+// cleanup runs before every exit, with the state lock held: it puts the
+// terminal back, saves the trainer and the names completion knows about
+// and stops the cache reapers.
+func cleanup() {
+	if EDITOR != nil {
+		EDITOR.restoreTerminal()
+	}
+	autosave()
+	saveKnownNames()
+	for _, cache := range []interface{ Stop() }{MAP_CACHE, REGION_CACHE, LOCATION_CACHE, EXPLORE_CACHE, CATCH_CACHE, SPRITE_CACHE, WHERE_CACHE, NAMES_CACHE, DEX_CACHE} {
+		cache.Stop()
+	}
+}
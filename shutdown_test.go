@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"pokedexcli/internal"
+	"testing"
+	"time"
+)
+
+// TestShutdownWaitsForCommand is the second Ctrl-C or SIGTERM arriving
+// while a command is running: nothing may be saved until it returns.
+func TestShutdownWaitsForCommand(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	savedTrainer, savedPokemon, savedCaught, savedSeen := TRAINER, POKEMON, CAUGHT, SEEN
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN = savedTrainer, savedPokemon, savedCaught, savedSeen
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+	loadTrainer("ash")
+	newCaches()
+
+	lockState()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		done <- shutdown(cancel, time.Second)
+	}()
+	<-ctx.Done()
+	select {
+	case <-done:
+		t.Fatalf("expected shutdown to wait for the command")
+	case <-time.After(20 * time.Millisecond):
+	}
+	// the command finishes what it was doing after being cancelled
+	CAUGHT["pikachu"] = caughtRecord{Level: 5}
+	TRAINER_CHANGED = true
+	unlockState()
+	if !<-done {
+		t.Fatalf("expected shutdown to save once the command returned")
+	}
+
+	err := loadTrainer("ash")
+	if err != nil || CAUGHT["pikachu"].Level != 5 {
+		t.Errorf("expected the pikachu to be saved, got %v, %v", CAUGHT, err)
+	}
+}
+
+func TestShutdownGivesUp(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	lockState()
+	defer unlockState()
+	if shutdown(nil, 10*time.Millisecond) {
+		t.Errorf("expected shutdown to give up on a command that doesn't return")
+	}
+	if _, err := os.Stat(dataPath("trainer")); err == nil {
+		t.Errorf("expected nothing to be saved")
+	}
+}
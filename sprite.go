@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"pokedexcli/internal"
+	"strconv"
+	"strings"
+)
+
+type colorMode int
+
+const (
+	colorASCII colorMode = iota
+	color256
+	colorTrue
+)
+
+// spriteGens are the generations --gen accepts; spriteURL picks the game
+// whose sprites stand in for each one.
+var spriteGens = []string{"i", "ii", "iii", "iv", "v", "vi", "vii", "viii"}
+
+func sprite(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args, "shiny", "back")
+	if len(words) < 1 {
+		fmt.Println("Usage: sprite <pokemon name> [--shiny] [--back] [--gen i-viii] [--color truecolor|256|ascii]")
+		return nil
+	}
+	mon, err := getPokemon(ctx, resolveName(words))
+	if err != nil {
+		return err
+	}
+	return showSprite(ctx, mon, flags)
+}
+
+// showSprite draws the sprite picked by flags, shared by sprite and
+// inspect --sprite.
+func showSprite(ctx context.Context, mon pokemonEntry, flags flagSet) error {
+	url, err := spriteURL(mon, flags.get("gen"), flags.has("shiny"), flags.has("back"))
+	if err != nil {
+		return err
+	}
+	if !flags.has("gen") {
+		// the active game's own sprite, when the API has one
+		info, err := activeGame(ctx)
+		if err != nil {
+			return err
+		}
+		if info != nil {
+			if urls, ok := gameSprites(mon, info); ok {
+				url, err = pickSprite(mon, urls, flags.has("shiny"), flags.has("back"))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	mode, err := terminalColorMode(flags.get("color"))
+	if err != nil {
+		return err
+	}
+	art, err := renderedSprite(ctx, url, mode, terminalWidth())
+	if err != nil {
+		return err
+	}
+	fmt.Print(art)
+	return nil
+}
+
+// renderedSprite returns the sprite at url drawn for mode and width.
+// Drawings are kept on disk, so a sprite is only fetched and decoded the
+// first time it's drawn that way.
+func renderedSprite(ctx context.Context, url string, mode colorMode, width int) (string, error) {
+	sum := sha256.Sum256([]byte(url))
+	path := dataPath(filepath.Join("sprites", fmt.Sprintf("%x-%d-%d.txt", sum[:12], mode, width)))
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return string(data), nil
+	}
+	img, err := getSprite(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	art := renderImage(img, mode, width)
+	// like the other files we keep, this is best effort
+	os.MkdirAll(filepath.Dir(path), 0700)
+	os.WriteFile(path, []byte(art), 0600)
+	return art, nil
+}
+
+// getPokemon returns the pokemon's data, fetching it if it hasn't been seen
+// yet. Unlike catch it doesn't add the pokemon to POKEMON.
+func getPokemon(ctx context.Context, name string) (pokemonEntry, error) {
+	mon, exists := POKEMON[name]
+	if exists {
+		return mon, nil
+	}
+	mon, lookup, err := fetchPokemon(ctx, name)
+	if err == nil && lookup == internal.KnownMissing {
+		return mon, fmt.Errorf("pokemon %s not found%s", name, didYouMean(ctx, name, true))
+	}
+	return mon, err
+}
+
+func spriteURL(mon pokemonEntry, gen string, shiny bool, back bool) (string, error) {
+	s := mon.Sprites
+	v := s.Versions
+	// front, front shiny, back, back shiny
+	var urls [4]string
+	switch gen {
+	case "":
+		urls = [4]string{s.FrontDefault, s.FrontShiny, s.BackDefault, s.BackShiny}
+	case "i":
+		urls = [4]string{v.GenerationI.RedBlue.FrontTransparent, "", v.GenerationI.RedBlue.BackTransparent, ""}
+	case "ii":
+		urls = [4]string{v.GenerationIi.Crystal.FrontTransparent, v.GenerationIi.Crystal.FrontShinyTransparent, v.GenerationIi.Crystal.BackTransparent, v.GenerationIi.Crystal.BackShinyTransparent}
+	case "iii":
+		urls = [4]string{v.GenerationIii.FireredLeafgreen.FrontDefault, v.GenerationIii.FireredLeafgreen.FrontShiny, v.GenerationIii.FireredLeafgreen.BackDefault, v.GenerationIii.FireredLeafgreen.BackShiny}
+	case "iv":
+		urls = [4]string{v.GenerationIv.Platinum.FrontDefault, v.GenerationIv.Platinum.FrontShiny, v.GenerationIv.Platinum.BackDefault, v.GenerationIv.Platinum.BackShiny}
+	case "v":
+		urls = [4]string{v.GenerationV.BlackWhite.FrontDefault, v.GenerationV.BlackWhite.FrontShiny, v.GenerationV.BlackWhite.BackDefault, v.GenerationV.BlackWhite.BackShiny}
+	case "vi":
+		urls = [4]string{v.GenerationVi.XY.FrontDefault, v.GenerationVi.XY.FrontShiny, "", ""}
+	case "vii":
+		urls = [4]string{v.GenerationVii.UltraSunUltraMoon.FrontDefault, v.GenerationVii.UltraSunUltraMoon.FrontShiny, "", ""}
+	case "viii":
+		urls = [4]string{v.GenerationViii.Icons.FrontDefault, "", "", ""}
+	default:
+		return "", fmt.Errorf("unknown generation %q, expected one of %s", gen, strings.Join(spriteGens, ", "))
+	}
+	return pickSprite(mon, urls, shiny, back)
+}
+
+// pickSprite picks from the front, front shiny, back and back shiny urls.
+func pickSprite(mon pokemonEntry, urls [4]string, shiny bool, back bool) (string, error) {
+	i := 0
+	if shiny {
+		i++
+	}
+	if back {
+		i += 2
+	}
+	if urls[i] == "" {
+		return "", fmt.Errorf("no such sprite for %s", mon.Name)
+	}
+	return urls[i], nil
+}
+
+func getSprite(ctx context.Context, url string) (image.Image, error) {
+	pngBytes, lookup, err := SPRITE_CACHE.Fetch(ctx, url, POKEMON_TTL, POKEMON_STALE, func(ctx context.Context) ([]byte, error) {
+		return API.Get(ctx, url)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lookup == internal.KnownMissing {
+		return nil, fmt.Errorf("sprite %s not found", url)
+	}
+	img, _, err := image.Decode(bytes.NewReader(pngBytes))
+	return img, err
+}
+
+// terminalColorMode picks how to draw colors. An explicit --color wins,
+// otherwise we go by COLORTERM and TERM the way most terminal tools do.
+func terminalColorMode(flag string) (colorMode, error) {
+	switch flag {
+	case "truecolor", "24bit":
+		return colorTrue, nil
+	case "256":
+		return color256, nil
+	case "ascii":
+		return colorASCII, nil
+	case "":
+	default:
+		return colorASCII, fmt.Errorf("unknown color mode %q, expected truecolor, 256 or ascii", flag)
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return colorASCII, nil
+	}
+	colorTerm := os.Getenv("COLORTERM")
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return colorTrue, nil
+	}
+	term := os.Getenv("TERM")
+	if term == "" || term == "dumb" {
+		return colorASCII, nil
+	}
+	return color256, nil
+}
+
+// terminalWidth asks the terminal how wide it is, falling back to COLUMNS,
+// which shells don't usually export, when stdout isn't a terminal.
+func terminalWidth() int {
+	if width, err := terminalColumns(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width <= 0 {
+		return 80
+	}
+	return width
+}
+
+// renderImage draws img with one character cell per two pixel rows. In the
+// color modes the upper half block takes the top pixel as its foreground
+// and the bottom pixel as its background; transparent pixels are left as
+// the terminal's own background. ASCII mode uses a brightness ramp instead.
+func renderImage(img image.Image, mode colorMode, maxWidth int) string {
+	bounds := opaqueBounds(img)
+	if bounds.Empty() {
+		return ""
+	}
+	scale := 1
+	for bounds.Dx()/scale > maxWidth {
+		scale++
+	}
+	width := bounds.Dx() / scale
+	height := bounds.Dy() / scale
+	pixel := func(x int, y int) (color.RGBA, bool) {
+		if y >= height {
+			return color.RGBA{}, false
+		}
+		return averagePixel(img, bounds.Min.X+x*scale, bounds.Min.Y+y*scale, scale)
+	}
+
+	var out strings.Builder
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x++ {
+			top, topOk := pixel(x, y)
+			bottom, bottomOk := pixel(x, y+1)
+			switch {
+			case mode == colorASCII:
+				out.WriteByte(asciiShade(top, topOk, bottom, bottomOk))
+			case topOk && bottomOk:
+				out.WriteString(fgColor(top, mode) + bgColor(bottom, mode) + "▀\x1b[0m")
+			case topOk:
+				out.WriteString(fgColor(top, mode) + "▀\x1b[0m")
+			case bottomOk:
+				out.WriteString(fgColor(bottom, mode) + "▄\x1b[0m")
+			default:
+				out.WriteByte(' ')
+			}
+		}
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+// opaqueBounds trims the transparent border most sprites are padded with.
+func opaqueBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	crop := image.Rectangle{Min: b.Max, Max: b.Min}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			if a >= 0x8000 {
+				crop.Min.X = min(crop.Min.X, x)
+				crop.Min.Y = min(crop.Min.Y, y)
+				crop.Max.X = max(crop.Max.X, x+1)
+				crop.Max.Y = max(crop.Max.Y, y+1)
+			}
+		}
+	}
+	return crop
+}
+
+// averagePixel averages the scale x scale block at (x0, y0). The block
+// counts as transparent when fewer than half its pixels are opaque.
+func averagePixel(img image.Image, x0 int, y0 int, scale int) (color.RGBA, bool) {
+	var r, g, b, n uint32
+	for y := y0; y < y0+scale; y++ {
+		for x := x0; x < x0+scale; x++ {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			if pa < 0x8000 {
+				continue
+			}
+			// undo the alpha premultiplication
+			r += pr * 0xffff / pa
+			g += pg * 0xffff / pa
+			b += pb * 0xffff / pa
+			n++
+		}
+	}
+	if n == 0 || n*2 < uint32(scale*scale) {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(b / n >> 8), A: 0xff}, true
+}
+
+func fgColor(c color.RGBA, mode colorMode) string {
+	if mode == colorTrue {
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("\x1b[38;5;%dm", xterm256(c))
+}
+
+func bgColor(c color.RGBA, mode colorMode) string {
+	if mode == colorTrue {
+		return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("\x1b[48;5;%dm", xterm256(c))
+}
+
+// xterm256 maps c to the closest entry of the 6x6x6 color cube or the
+// 24-step gray ramp in the xterm 256 color palette.
+func xterm256(c color.RGBA) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(v uint8) int {
+		best := 0
+		for i, level := range levels {
+			if absInt(int(v)-level) < absInt(int(v)-levels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := nearest(c.R), nearest(c.G), nearest(c.B)
+	cube := 16 + 36*r + 6*g + b
+	cubeDist := sqDist(c, levels[r], levels[g], levels[b])
+
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	grayStep := min(max((avg-8+5)/10, 0), 23)
+	grayLevel := 8 + 10*grayStep
+	if sqDist(c, grayLevel, grayLevel, grayLevel) < cubeDist {
+		return 232 + grayStep
+	}
+	return cube
+}
+
+func sqDist(c color.RGBA, r int, g int, b int) int {
+	dr, dg, db := int(c.R)-r, int(c.G)-g, int(c.B)-b
+	return dr*dr + dg*dg + db*db
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+const asciiRamp = " .:-=+*#%@"
+
+// asciiShade picks a character by the brightness of the two pixels a cell
+// covers, darker pixels getting denser characters.
+func asciiShade(top color.RGBA, topOk bool, bottom color.RGBA, bottomOk bool) byte {
+	var lum, n int
+	for _, p := range []struct {
+		c  color.RGBA
+		ok bool
+	}{{top, topOk}, {bottom, bottomOk}} {
+		if p.ok {
+			lum += (299*int(p.c.R) + 587*int(p.c.G) + 114*int(p.c.B)) / 1000
+			n++
+		}
+	}
+	if n == 0 {
+		return ' '
+	}
+	darkness := 255 - lum/n
+	return asciiRamp[1+darkness*(len(asciiRamp)-2)/255]
+}
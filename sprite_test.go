@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRenderImage(t *testing.T) {
+	// a 4x4 canvas with a 2x2 red square in the middle
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 1; y < 3; y++ {
+		for x := 1; x < 3; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	cases := []struct {
+		mode     colorMode
+		expected string
+	}{
+		{
+			mode:     colorTrue,
+			expected: strings.Repeat("\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀\x1b[0m", 2) + "\n",
+		},
+		{
+			mode:     color256,
+			expected: strings.Repeat("\x1b[38;5;196m\x1b[48;5;196m▀\x1b[0m", 2) + "\n",
+		},
+		{
+			mode:     colorASCII,
+			expected: "**\n",
+		},
+	}
+	for _, c := range cases {
+		actual := renderImage(img, c.mode, 80)
+		if actual != c.expected {
+			t.Errorf("EXPECTED: %q\tACTUAL: %q", c.expected, actual)
+		}
+	}
+}
+
+func TestRenderImageHalfBlocks(t *testing.T) {
+	// only the top pixel of the second column is opaque
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 1, color.RGBA{G: 255, A: 255})
+	img.Set(1, 0, color.RGBA{G: 255, A: 255})
+	actual := renderImage(img, colorTrue, 80)
+	expected := "\x1b[38;2;0;255;0m▄\x1b[0m\x1b[38;2;0;255;0m▀\x1b[0m\n"
+	if actual != expected {
+		t.Errorf("EXPECTED: %q\tACTUAL: %q", expected, actual)
+	}
+}
+
+func TestRenderImageScalesToWidth(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{B: 255, A: 255})
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(renderImage(img, colorASCII, 10), "\n"), "\n")
+	if len(lines) != 5 || len(lines[0]) != 10 {
+		t.Errorf("Expected 10x5 characters, got %vx%v", len(lines[0]), len(lines))
+	}
+}
+
+func TestXterm256(t *testing.T) {
+	cases := []struct {
+		c        color.RGBA
+		expected int
+	}{
+		{color.RGBA{R: 255}, 196},
+		{color.RGBA{}, 16},
+		{color.RGBA{R: 128, G: 128, B: 128}, 244},
+	}
+	for _, c := range cases {
+		if actual := xterm256(c.c); actual != c.expected {
+			t.Errorf("%v: EXPECTED: %v\tACTUAL: %v", c.c, c.expected, actual)
+		}
+	}
+}
+
+func TestRenderedSpriteKept(t *testing.T) {
+	useTestMapAPI(t)
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	url := "https://raw.githubusercontent.com/PokeAPI/sprites/master/sprites/pokemon/25.png"
+	API.HTTPClient = &http.Client{Transport: apiTransport{url: buf.String()}}
+	ctx := context.Background()
+	art, err := renderedSprite(ctx, url, colorASCII, 80)
+	if err != nil || art != "**\n" {
+		t.Fatalf("expected the sprite drawn, got %q, %v", art, err)
+	}
+
+	// a later run draws it again without the API
+	newCaches()
+	API.HTTPClient = &http.Client{Transport: failingTransport{}}
+	API.MaxAttempts = 1
+	again, err := renderedSprite(ctx, url, colorASCII, 80)
+	if err != nil || again != art {
+		t.Errorf("expected the drawing kept on disk, got %q, %v", again, err)
+	}
+	if _, err := renderedSprite(ctx, url, colorTrue, 80); err == nil {
+		t.Errorf("expected another color mode to need drawing again")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+// makeRaw is only implemented for Linux; elsewhere the line editor falls
+// back to reading whole lines.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+// terminalColumns is only implemented for Linux; elsewhere COLUMNS is used.
+func terminalColumns(fd int) (int, error) {
+	return 0, errors.New("terminal size is not supported on this platform")
+}
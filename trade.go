@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"pokedexcli/internal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tradeFile is what trade export writes. The payload is signed compacted,
+// so the file can be indented without breaking the signature. It's signed
+// with the sending trainer's key, which only trainers on this machine have.
+type tradeFile struct {
+	Payload   json.RawMessage `json:"payload"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+// tradePayload carries everything the receiving trainer needs, so a trade
+// file can be imported without the sender's save.
+type tradePayload struct {
+	Version  int          `json:"version"`
+	ID       string       `json:"id"`
+	From     string       `json:"from"`
+	Exported time.Time    `json:"exported"`
+	Pokemon  pokemonEntry `json:"pokemon"`
+	Record   caughtRecord `json:"record"`
+}
+
+const TRADE_VERSION = 1
+
+// evolutionLink is a species in an evolution chain and what it evolves into.
+type evolutionLink struct {
+	Species          namedResource `json:"species"`
+	EvolutionDetails []struct {
+		Trigger      namedResource  `json:"trigger"`
+		HeldItem     *namedResource `json:"held_item"`
+		TradeSpecies *namedResource `json:"trade_species"`
+	} `json:"evolution_details"`
+	EvolvesTo []evolutionLink `json:"evolves_to"`
+}
+
+// tradeKey returns the trainer's signing key, making one the first time
+// they trade.
+func tradeKey() (ed25519.PrivateKey, error) {
+	path := trainerPath("trade.key")
+	seed, err := os.ReadFile(path)
+	if err == nil && len(seed) == ed25519.SeedSize {
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	os.MkdirAll(filepath.Dir(path), 0700)
+	return key, os.WriteFile(path, key.Seed(), 0600)
+}
+
+func signTrade(payload tradePayload, key ed25519.PrivateKey) (tradeFile, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return tradeFile{}, err
+	}
+	return tradeFile{
+		Payload:   data,
+		PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: hex.EncodeToString(ed25519.Sign(key, data)),
+	}, nil
+}
+
+// trainerPublicKey returns the key a trainer on this machine signs trades
+// with.
+func trainerPublicKey(name string) (ed25519.PublicKey, error) {
+	if !trainerNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid trainer name %q", name)
+	}
+	seed, err := os.ReadFile(dataPath(filepath.Join("trainers", name, "trade.key")))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("trainer %s has never traded on this machine", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("trainer %s's trade key is damaged", name)
+	}
+	return ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey), nil
+}
+
+// openTrade checks a trade file was signed by the trainer it says it's from
+// and wasn't changed since, and returns what's in it. A key we don't know
+// proves nothing, since anyone can make one and sign whatever they like.
+func openTrade(trade tradeFile) (tradePayload, error) {
+	var payload tradePayload
+	err := json.Unmarshal(trade.Payload, &payload)
+	if err != nil {
+		return payload, err
+	}
+	publicKey, err := trainerPublicKey(payload.From)
+	if err != nil {
+		return payload, fmt.Errorf("can't check who the trade is from: %v", err)
+	}
+	if trade.PublicKey != hex.EncodeToString(publicKey) {
+		return payload, fmt.Errorf("trade file wasn't signed by %s", payload.From)
+	}
+	var compact bytes.Buffer
+	err = json.Compact(&compact, trade.Payload)
+	if err != nil {
+		return payload, err
+	}
+	signature, err := hex.DecodeString(trade.Signature)
+	if err != nil || !ed25519.Verify(publicKey, compact.Bytes(), signature) {
+		return payload, errors.New("trade file signature doesn't match, it may have been tampered with")
+	}
+	if payload.Version != TRADE_VERSION {
+		return payload, fmt.Errorf("trade file version %d isn't supported", payload.Version)
+	}
+	if payload.ID == "" || payload.Pokemon.Name == "" {
+		return payload, errors.New("trade file is incomplete")
+	}
+	// the species is looked up when checking for trade evolutions
+	if !strings.HasPrefix(payload.Pokemon.Species.URL, "https://pokeapi.co/api/v2/pokemon-species/") {
+		return payload, fmt.Errorf("trade file has an unexpected species link %q", payload.Pokemon.Species.URL)
+	}
+	return payload, nil
+}
+
+// tradeEvolution returns the species that species evolves into when
+// traded, or "" if it doesn't. Evolutions needing a held item or a
+// particular trading partner are left out, since there are no items and
+// the partner isn't known.
+func tradeEvolution(link evolutionLink, species string) string {
+	if link.Species.Name == species {
+		for _, next := range link.EvolvesTo {
+			for _, detail := range next.EvolutionDetails {
+				if detail.Trigger.Name == "trade" && detail.HeldItem == nil && detail.TradeSpecies == nil {
+					return next.Species.Name
+				}
+			}
+		}
+		return ""
+	}
+	for _, next := range link.EvolvesTo {
+		if evolved := tradeEvolution(next, species); evolved != "" {
+			return evolved
+		}
+	}
+	return ""
+}
+
+// evolveOnTrade looks up mon's evolution chain and returns what it evolves
+// into when traded, or false if it stays as it is. Species such as
+// gourgeist have no pokemon of their own name, so it evolves into the
+// species' default variety.
+func evolveOnTrade(ctx context.Context, mon pokemonEntry) (pokemonEntry, bool, error) {
+	species, err := getSpecies(ctx, mon)
+	if err != nil {
+		return mon, false, err
+	}
+	if species.EvolutionChain.URL == "" {
+		return mon, false, nil
+	}
+	var chain struct {
+		Chain evolutionLink `json:"chain"`
+	}
+	err = API.GetJSON(ctx, species.EvolutionChain.URL, &chain)
+	if err != nil {
+		return mon, false, err
+	}
+	evolved := tradeEvolution(chain.Chain, mon.Species.Name)
+	if evolved == "" {
+		return mon, false, nil
+	}
+	var stub pokemonEntry
+	stub.Species.Name = evolved
+	stub.Species.URL = fmt.Sprintf("https://pokeapi.co/api/v2/pokemon-species/%s", evolved)
+	species, err = getSpecies(ctx, stub)
+	if err != nil {
+		return mon, false, err
+	}
+	for _, variety := range species.Varieties {
+		if !variety.IsDefault {
+			continue
+		}
+		next, lookup, err := fetchPokemon(ctx, variety.Pokemon.Name)
+		if err != nil {
+			return mon, false, err
+		}
+		if lookup == internal.KnownMissing {
+			break
+		}
+		return next, true, nil
+	}
+	return mon, false, fmt.Errorf("%s evolves into %s, but there's no pokemon for it", mon.Name, evolved)
+}
+
+// importedTradesPath lists the trades imported on this machine, for every
+// trainer, so one trade file can't hand out the same pokemon twice.
+func importedTradesPath() string {
+	return dataPath("trades-imported")
+}
+
+func importedTrades() ([]string, error) {
+	data, err := os.ReadFile(importedTradesPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return strings.Fields(string(data)), err
+}
+
+func markTradeImported(id string) error {
+	path := importedTradesPath()
+	os.MkdirAll(filepath.Dir(path), 0700)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(id + "\n")
+	return err
+}
+
+// findCaught finds a caught pokemon by name, or by pokedex number.
+func findCaught(arg string) (string, bool) {
+	if _, exists := CAUGHT[arg]; exists {
+		return arg, true
+	}
+	if id, err := strconv.Atoi(arg); err == nil {
+		for name := range CAUGHT {
+			if POKEMON[name].ID == id {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func trade(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args)
+	if len(words) < 2 || (words[0] != "export" && words[0] != "import") {
+		fmt.Println("Usage: trade export <pokemon|id> [--out <file>] | trade import <file>")
+		return nil
+	}
+	if words[0] == "export" {
+		return exportTrade(ctx, resolveName(words[1:]), flags.get("out"))
+	}
+	return importTrade(ctx, words[1])
+}
+
+// exportTrade writes a caught pokemon to a trade file and lets it go.
+func exportTrade(ctx context.Context, arg string, out string) error {
+	name, exists := findCaught(arg)
+	if !exists {
+		fmt.Printf("You haven't caught %s\n", arg)
+		return nil
+	}
+	key, err := tradeKey()
+	if err != nil {
+		return fmt.Errorf("could not get a signing key: %v", err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	payload := tradePayload{
+		Version:  TRADE_VERSION,
+		ID:       hex.EncodeToString(id),
+		From:     TRAINER,
+		Exported: time.Now(),
+		Pokemon:  POKEMON[name],
+		Record:   CAUGHT[name],
+	}
+	signed, err := signTrade(payload, key)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		out = fmt.Sprintf("%s-%s.trade", name, payload.ID[:8])
+	}
+	// O_EXCL so an old trade file is never written over
+	file, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+
+	delete(CAUGHT, name)
+	TRAINER_CHANGED = true
+	if i := slices.Index(PARTY, name); i >= 0 {
+		PARTY = slices.Delete(PARTY, i, i+1)
+	}
+	recordEncounter(encounterEvent{Event: "traded", Pokemon: name, Game: GAME})
+	err = saveTrainer()
+	if err != nil {
+		return fmt.Errorf("traded %s to %s but could not save: %v", name, out, err)
+	}
+	displayName := localName(ctx, "pokemon-species", name)
+	fmt.Printf("%s was packed into %s. Bye, %s!\n", displayName, out, displayName)
+	return nil
+}
+
+// importTrade adds the pokemon in a trade file to the trainer's box,
+// evolving it if trading makes it evolve.
+func importTrade(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var signed tradeFile
+	err = json.Unmarshal(data, &signed)
+	if err != nil {
+		return fmt.Errorf("%s isn't a trade file: %v", path, err)
+	}
+	payload, err := openTrade(signed)
+	if err != nil {
+		return err
+	}
+	if payload.From == TRAINER {
+		return fmt.Errorf("%s came from you, trade it to another trainer", payload.Pokemon.Name)
+	}
+	imported, err := importedTrades()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(imported, payload.ID) {
+		return fmt.Errorf("this trade has already been imported")
+	}
+	mon := payload.Pokemon
+	if _, exists := CAUGHT[mon.Name]; exists {
+		return fmt.Errorf("you already have a %s, and can only keep one of each", mon.Name)
+	}
+
+	// nothing is kept until we know whether it evolves, so the trade can
+	// be imported again once the API answers
+	evolved, evolves, err := evolveOnTrade(ctx, mon)
+	if err != nil {
+		return fmt.Errorf("could not check whether %s evolves when traded, try again: %v", mon.Name, err)
+	}
+	if _, exists := CAUGHT[evolved.Name]; evolves && exists {
+		return fmt.Errorf("%s would evolve into %s, and you already have one", mon.Name, evolved.Name)
+	}
+	err = markTradeImported(payload.ID)
+	if err != nil {
+		return fmt.Errorf("could not note the trade as imported: %v", err)
+	}
+
+	displayName := localName(ctx, "pokemon-species", mon.Name)
+	fmt.Printf("%s sent over %s!\n", payload.From, displayName)
+	if evolves {
+		fmt.Printf("What? %s is evolving!\n", displayName)
+		fmt.Printf("%s evolved into %s!\n", displayName, localName(ctx, "pokemon-species", evolved.Name))
+		mon = evolved
+	}
+	POKEMON[mon.Name] = mon
+	KNOWN_POKEMON[mon.Name] = struct{}{}
+	CAUGHT[mon.Name] = payload.Record
+	joinParty(mon.Name)
+	TRAINER_CHANGED = true
+	recordEncounter(encounterEvent{Event: "received", Pokemon: mon.Name, Game: GAME})
+	return saveTrainer()
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"pokedexcli/internal"
+	"testing"
+	"time"
+)
+
+func TestSignTrade(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	defer func(name string) { TRAINER = name }(TRAINER)
+	TRAINER = "ash"
+	key, err := tradeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kadabra pokemonEntry
+	kadabra.Name = "kadabra"
+	kadabra.Species.URL = "https://pokeapi.co/api/v2/pokemon-species/64/"
+	payload := tradePayload{Version: TRADE_VERSION, ID: "abc", From: "ash", Pokemon: kadabra, Record: caughtRecord{Level: 16}}
+	signed, err := signTrade(payload, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := openTrade(signed)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if opened.Pokemon.Name != "kadabra" || opened.Record.Level != 16 || opened.From != "ash" {
+		t.Errorf("expected ash's level 16 kadabra, got %+v", opened)
+	}
+
+	tampered := payload
+	tampered.Record.Level = 100
+	data, _ := json.Marshal(tampered)
+	edited := signed
+	edited.Payload = data
+	if _, err := openTrade(edited); err == nil {
+		t.Errorf("expected a tampered trade to be rejected")
+	}
+
+	// a forger can sign whatever they like with a key of their own
+	var mewtwo pokemonEntry
+	mewtwo.Name = "mewtwo"
+	mewtwo.Species.URL = "https://pokeapi.co/api/v2/pokemon-species/150/"
+	forged := tradePayload{Version: TRADE_VERSION, ID: "fresh", From: "ash", Pokemon: mewtwo, Record: caughtRecord{Level: 100}}
+	_, forgerKey, _ := ed25519.GenerateKey(nil)
+	resigned, err := signTrade(forged, forgerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openTrade(resigned); err == nil {
+		t.Errorf("expected a trade signed with an unknown key to be rejected")
+	}
+	forged.From = "gary"
+	resigned, _ = signTrade(forged, forgerKey)
+	if _, err := openTrade(resigned); err == nil {
+		t.Errorf("expected a trade from a trainer we don't know to be rejected")
+	}
+
+	// even ash's own key can't send the API somewhere else
+	kadabra.Species.URL = "https://example.com/pokemon-species/64/"
+	payload.Pokemon = kadabra
+	signed, _ = signTrade(payload, key)
+	if _, err := openTrade(signed); err == nil {
+		t.Errorf("expected a species link off pokeapi.co to be rejected")
+	}
+}
+
+func TestTradeEvolution(t *testing.T) {
+	var abra, onix, karrablast evolutionLink
+	json.Unmarshal([]byte(`{"species": {"name": "abra"}, "evolves_to": [
+		{"species": {"name": "kadabra"}, "evolution_details": [{"trigger": {"name": "level-up"}}], "evolves_to": [
+			{"species": {"name": "alakazam"}, "evolution_details": [{"trigger": {"name": "trade"}}]}
+		]}
+	]}`), &abra)
+	json.Unmarshal([]byte(`{"species": {"name": "onix"}, "evolves_to": [
+		{"species": {"name": "steelix"}, "evolution_details": [{"trigger": {"name": "trade"}, "held_item": {"name": "metal-coat"}}]}
+	]}`), &onix)
+	json.Unmarshal([]byte(`{"species": {"name": "karrablast"}, "evolves_to": [
+		{"species": {"name": "escavalier"}, "evolution_details": [{"trigger": {"name": "trade"}, "trade_species": {"name": "shelmet"}}]}
+	]}`), &karrablast)
+
+	cases := []struct {
+		chain    evolutionLink
+		species  string
+		expected string
+	}{
+		{abra, "kadabra", "alakazam"},
+		{abra, "abra", ""},
+		{abra, "alakazam", ""},
+		{onix, "onix", ""},
+		{karrablast, "karrablast", ""},
+	}
+	for _, c := range cases {
+		if actual := tradeEvolution(c.chain, c.species); actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.species, c.expected, actual)
+		}
+	}
+}
+
+func TestTradeBetweenTrainers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("POKEDEX_HOME", dir)
+	API = internal.NewClient(time.Second)
+	savedTrainer, savedPokemon, savedCaught, savedSeen, savedSpecies := TRAINER, POKEMON, CAUGHT, SEEN, SPECIES
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN, SPECIES = savedTrainer, savedPokemon, savedCaught, savedSeen, savedSpecies
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+	// no evolution chain, so importing doesn't need the API
+	SPECIES = map[string]pokemonSpecies{"pikachu": {Name: "pikachu"}}
+	ctx := context.Background()
+
+	loadTrainer("ash")
+	var pikachu pokemonEntry
+	pikachu.ID, pikachu.Name = 25, "pikachu"
+	pikachu.Species.Name = "pikachu"
+	pikachu.Species.URL = "https://pokeapi.co/api/v2/pokemon-species/25/"
+	POKEMON["pikachu"] = pikachu
+	CAUGHT["pikachu"] = caughtRecord{Level: 5}
+	joinParty("pikachu")
+
+	out := filepath.Join(dir, "pikachu.trade")
+	err := exportTrade(ctx, "25", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := CAUGHT["pikachu"]; exists || len(PARTY) != 0 {
+		t.Errorf("expected pikachu to leave ash, got %v, %v", CAUGHT, PARTY)
+	}
+	if importTrade(ctx, out) == nil {
+		t.Errorf("expected ash not to be able to trade with themselves")
+	}
+
+	err = switchTrainer("misty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = importTrade(ctx, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CAUGHT["pikachu"].Level != 5 || POKEMON["pikachu"].ID != 25 || len(PARTY) != 1 {
+		t.Errorf("expected misty to have ash's pikachu, got %v, %v", CAUGHT, PARTY)
+	}
+
+	err = switchTrainer("brock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if importTrade(ctx, out) == nil {
+		t.Errorf("expected a second import of the same trade to be refused")
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("expected the trade file to be left alone, got %v", err)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("network is down")
+}
+
+func TestTradeImportRetriesWhenEvolutionUnknown(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("POKEDEX_HOME", dir)
+	API = internal.NewClient(time.Second)
+	savedTrainer, savedPokemon, savedCaught, savedSeen, savedSpecies := TRAINER, POKEMON, CAUGHT, SEEN, SPECIES
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN, SPECIES = savedTrainer, savedPokemon, savedCaught, savedSeen, savedSpecies
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+	SPECIES = make(map[string]pokemonSpecies)
+	ctx := context.Background()
+
+	loadTrainer("ash")
+	var kadabra pokemonEntry
+	kadabra.ID, kadabra.Name = 64, "kadabra"
+	kadabra.Species.Name = "kadabra"
+	kadabra.Species.URL = "https://pokeapi.co/api/v2/pokemon-species/64/"
+	POKEMON["kadabra"] = kadabra
+	CAUGHT["kadabra"] = caughtRecord{Level: 16}
+	out := filepath.Join(dir, "kadabra.trade")
+	err := exportTrade(ctx, "kadabra", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = switchTrainer("misty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	API.HTTPClient = &http.Client{Transport: failingTransport{}}
+	API.MaxAttempts = 1
+	if importTrade(ctx, out) == nil {
+		t.Fatalf("expected the import to fail while the API can't be reached")
+	}
+	if _, exists := CAUGHT["kadabra"]; exists {
+		t.Errorf("expected nothing to be kept from a failed import")
+	}
+
+	// once the species can be looked up the same trade goes through
+	SPECIES["kadabra"] = pokemonSpecies{Name: "kadabra"}
+	err = importTrade(ctx, out)
+	if err != nil {
+		t.Fatalf("expected the trade to be importable again, got %v", err)
+	}
+	if CAUGHT["kadabra"].Level != 16 {
+		t.Errorf("expected misty to have the kadabra, got %v", CAUGHT)
+	}
+}
+
+func TestTradeEvolvesIntoDefaultVariety(t *testing.T) {
+	useTestMapAPI(t)
+	dir := os.Getenv("POKEDEX_HOME")
+	savedTrainer, savedPokemon, savedCaught, savedSeen, savedSpecies := TRAINER, POKEMON, CAUGHT, SEEN, SPECIES
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN, SPECIES = savedTrainer, savedPokemon, savedCaught, savedSeen, savedSpecies
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+	SPECIES = make(map[string]pokemonSpecies)
+	responses := apiTransport{
+		"https://pokeapi.co/api/v2/pokemon-species/710/":      `{"name": "pumpkaboo", "evolution_chain": {"url": "https://pokeapi.co/api/v2/evolution-chain/363/"}}`,
+		"https://pokeapi.co/api/v2/evolution-chain/363/":      `{"chain": {"species": {"name": "pumpkaboo"}, "evolves_to": [{"species": {"name": "gourgeist"}, "evolution_details": [{"trigger": {"name": "trade"}}]}]}}`,
+		"https://pokeapi.co/api/v2/pokemon-species/gourgeist": `{"name": "gourgeist", "varieties": []}`,
+		"https://pokeapi.co/api/v2/pokemon/gourgeist-average": `{"id": 711, "name": "gourgeist-average", "species": {"name": "gourgeist"}}`,
+	}
+	API.HTTPClient = &http.Client{Transport: responses}
+	ctx := context.Background()
+
+	loadTrainer("ash")
+	var pumpkaboo pokemonEntry
+	pumpkaboo.ID, pumpkaboo.Name = 710, "pumpkaboo-average"
+	pumpkaboo.Species.Name = "pumpkaboo"
+	pumpkaboo.Species.URL = "https://pokeapi.co/api/v2/pokemon-species/710/"
+	POKEMON["pumpkaboo-average"] = pumpkaboo
+	CAUGHT["pumpkaboo-average"] = caughtRecord{Level: 20}
+	out := filepath.Join(dir, "pumpkaboo.trade")
+	err := exportTrade(ctx, "pumpkaboo-average", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = switchTrainer("misty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an evolution with no pokemon to evolve into fails rather than being
+	// dropped
+	if importTrade(ctx, out) == nil {
+		t.Fatalf("expected an error when gourgeist has no default variety")
+	}
+	if len(CAUGHT) != 0 {
+		t.Errorf("expected nothing to be kept from a failed import, got %v", CAUGHT)
+	}
+
+	delete(SPECIES, "gourgeist")
+	responses["https://pokeapi.co/api/v2/pokemon-species/gourgeist"] = `{"name": "gourgeist", "varieties": [{"is_default": false, "pokemon": {"name": "gourgeist-small"}}, {"is_default": true, "pokemon": {"name": "gourgeist-average"}}]}`
+
+	// a trade that can't be kept says nothing about arriving
+	CAUGHT["gourgeist-average"] = caughtRecord{Level: 30}
+	output := captureStdout(t, func() {
+		err = importTrade(ctx, out)
+	})
+	if err == nil || output != "" {
+		t.Errorf("expected only an error when misty already has a gourgeist-average, got %q, %v", output, err)
+	}
+	delete(CAUGHT, "gourgeist-average")
+
+	err = importTrade(ctx, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CAUGHT["gourgeist-average"].Level != 20 || POKEMON["gourgeist-average"].ID != 711 {
+		t.Errorf("expected the pumpkaboo to arrive as gourgeist-average, got %v", CAUGHT)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TRAINER is the trainer playing, whose save slot the pokedex, party,
+// settings and encounter log are loaded from and saved to.
+var TRAINER = "default"
+
+// PARTY is the trainer's first six pokemon caught.
+var PARTY []string
+
+const PARTY_SIZE = 6
+
+// INVENTORY is the trainer's bag, item names to how many they have. No
+// command hands out items yet, but each trainer has their own.
+var INVENTORY = make(map[string]int)
+
+// TRAINER_SETTINGS holds the settings the trainer changed with set. Settings
+// given as flags on the command line are only for that run, so aren't
+// saved.
+var TRAINER_SETTINGS = make(map[string]string)
+
+// DEFAULT_SETTINGS are the settings a new trainer starts with.
+var DEFAULT_SETTINGS map[string]string
+
+// saveSlot is what's kept on disk for a trainer. The encounter log sits
+// next to it.
+type saveSlot struct {
+	Name      string                  `json:"name"`
+	Created   time.Time               `json:"created"`
+	Saved     time.Time               `json:"saved"`
+	Pokemon   map[string]pokemonEntry `json:"pokemon"`
+	Caught    map[string]caughtRecord `json:"caught"`
+	Party     []string                `json:"party"`
+	Inventory map[string]int          `json:"inventory"`
+	Settings  map[string]string       `json:"settings"`
+	Map       savedMapPage            `json:"map"`
+}
+
+type savedMapPage struct {
+	Region string `json:"region,omitempty"`
+	Size   int    `json:"size"`
+	Offset int    `json:"offset"`
+}
+
+var TRAINER_CREATED time.Time
+
+// TRAINER_CHANGED is set by commands that change what's in the save slot,
+// and cleared once it's saved.
+var TRAINER_CHANGED bool
+
+// SAVE_BLOCKED is set when the trainer's save couldn't be read, so that
+// playing on doesn't write over it.
+var SAVE_BLOCKED bool
+
+var errSaveDamaged = errors.New("save is damaged")
+
+var trainerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// trainerPath returns where the file name lives in the current trainer's
+// save slot.
+func trainerPath(name string) string {
+	return dataPath(filepath.Join("trainers", TRAINER, name))
+}
+
+func snapshotSettings() map[string]string {
+	values := make(map[string]string)
+	for _, s := range createSettings() {
+		values[s.name] = s.get()
+	}
+	return values
+}
+
+// loadCurrentTrainer loads the trainer that was playing last time.
+func loadCurrentTrainer() {
+	DEFAULT_SETTINGS = snapshotSettings()
+	name := "default"
+	data, err := os.ReadFile(dataPath("trainer"))
+	if err == nil && trainerNamePattern.MatchString(strings.TrimSpace(string(data))) {
+		name = strings.TrimSpace(string(data))
+	}
+	err = loadTrainer(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load trainer %s: %v\n", name, err)
+	}
+	if err != nil && !errors.Is(err, errSaveDamaged) {
+		fmt.Fprintf(os.Stderr, "Playing on without saving, so the save isn't lost\n")
+		TRAINER = name
+		SAVE_BLOCKED = true
+	}
+}
+
+// loadTrainer replaces everything the current trainer has with what's in
+// name's save slot. A trainer without a save yet starts from scratch, and
+// so does one whose save is damaged, after moving it out of the way so
+// autosave doesn't write over it. When the save can't be read at all
+// nothing is replaced.
+func loadTrainer(name string) error {
+	path := dataPath(filepath.Join("trainers", name, "save.json"))
+	var slot saveSlot
+	var damaged error
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &slot)
+		if err != nil {
+			os.Rename(path, path+".damaged")
+			slot = saveSlot{}
+			damaged = fmt.Errorf("%w, moved it to %s: %v", errSaveDamaged, path+".damaged", err)
+		}
+	}
+
+	TRAINER = name
+	SAVE_BLOCKED = false
+	TRAINER_CHANGED = false
+	POKEMON = make(map[string]pokemonEntry)
+	CAUGHT = make(map[string]caughtRecord)
+	SEEN = make(map[string]map[string]bool)
+	PARTY = nil
+	INVENTORY = make(map[string]int)
+	CURRENT_AREA = ""
+	MAP_PAGE = mapPage{size: 20}
+	TRAINER_SETTINGS = make(map[string]string)
+	TRAINER_CREATED = time.Now()
+	// the encounter log was kept outside any save slot before there were
+	// trainers
+	if name == "default" {
+		if _, err := os.Stat(trainerPath("encounters.jsonl")); errors.Is(err, fs.ErrNotExist) {
+			os.MkdirAll(filepath.Dir(trainerPath("encounters.jsonl")), 0700)
+			os.Rename(dataPath("encounters.jsonl"), trainerPath("encounters.jsonl"))
+		}
+	}
+
+	for name, mon := range slot.Pokemon {
+		POKEMON[name] = mon
+		KNOWN_POKEMON[name] = struct{}{}
+	}
+	for name, record := range slot.Caught {
+		CAUGHT[name] = record
+	}
+	PARTY = slot.Party
+	for item, count := range slot.Inventory {
+		INVENTORY[item] = count
+	}
+	for name, value := range slot.Settings {
+		TRAINER_SETTINGS[name] = value
+	}
+	if !slot.Created.IsZero() {
+		TRAINER_CREATED = slot.Created
+	}
+	if slot.Map.Size > 0 {
+		MAP_PAGE = mapPage{region: slot.Map.Region, size: slot.Map.Size, offset: slot.Map.Offset}
+	}
+	for _, s := range createSettings() {
+		value, changed := TRAINER_SETTINGS[s.name]
+		if !changed {
+			value = DEFAULT_SETTINGS[s.name]
+		}
+		if err := s.set(value); err != nil {
+			verbosef("could not restore setting %s: %v", s.name, err)
+		}
+	}
+	loadEncounterLog()
+	return damaged
+}
+
+// saveTrainer writes the current trainer's save slot. The old save is only
+// replaced once the new one is safely written.
+func saveTrainer() error {
+	if SAVE_BLOCKED {
+		return fmt.Errorf("not saving over %s's save, which couldn't be read", TRAINER)
+	}
+	pokemon := make(map[string]pokemonEntry)
+	for name, mon := range POKEMON {
+		pokemon[name] = trimPokemon(mon)
+	}
+	slot := saveSlot{
+		Name:      TRAINER,
+		Created:   TRAINER_CREATED,
+		Saved:     time.Now(),
+		Pokemon:   pokemon,
+		Caught:    CAUGHT,
+		Party:     PARTY,
+		Inventory: INVENTORY,
+		Settings:  TRAINER_SETTINGS,
+		Map:       savedMapPage{Region: MAP_PAGE.region, Size: MAP_PAGE.size, Offset: MAP_PAGE.offset},
+	}
+	data, err := json.Marshal(slot)
+	if err != nil {
+		return err
+	}
+	path := trainerPath("save.json")
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path+".tmp", data, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(dataPath("trainer"), []byte(TRAINER+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+	TRAINER_CHANGED = false
+	return nil
+}
+
+// trimPokemon drops what the API tells us about mon that we never look at,
+// which is most of it: moves are only shown when learned by levelling up.
+func trimPokemon(mon pokemonEntry) pokemonEntry {
+	var unused pokemonEntry
+	mon.Cries = unused.Cries
+	mon.Forms = nil
+	mon.GameIndices = nil
+	mon.HeldItems = nil
+	mon.PastAbilities = nil
+	mon.PastTypes = nil
+	mon.Sprites.Other = unused.Sprites.Other
+	moves := mon.Moves[:0:0]
+	for _, move := range mon.Moves {
+		details := move.VersionGroupDetails[:0:0]
+		for _, detail := range move.VersionGroupDetails {
+			if detail.MoveLearnMethod.Name == "level-up" {
+				details = append(details, detail)
+			}
+		}
+		if len(details) > 0 {
+			move.VersionGroupDetails = details
+			moves = append(moves, move)
+		}
+	}
+	mon.Moves = moves
+	return mon
+}
+
+// autosave saves the trainer after a command changes it and on the way
+// out, saying so only if it fails.
+func autosave() {
+	if !TRAINER_CHANGED {
+		return
+	}
+	err := saveTrainer()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not save trainer %s: %v\n", TRAINER, err)
+	}
+}
+
+// joinParty adds a newly caught pokemon to the party if there's room.
+func joinParty(name string) {
+	if len(PARTY) < PARTY_SIZE {
+		PARTY = append(PARTY, name)
+	}
+}
+
+func listTrainers() ([]string, error) {
+	entries, err := os.ReadDir(dataPath("trainers"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	names := []string{TRAINER}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != TRAINER && trainerNamePattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func trainerExists(name string) bool {
+	_, err := os.Stat(dataPath(filepath.Join("trainers", name, "save.json")))
+	return err == nil
+}
+
+// switchTrainer saves the current trainer and loads name. A trainer whose
+// save couldn't be read is left as it is.
+func switchTrainer(name string) error {
+	if !SAVE_BLOCKED {
+		err := saveTrainer()
+		if err != nil {
+			return fmt.Errorf("could not save trainer %s: %v", TRAINER, err)
+		}
+	}
+	err := loadTrainer(name)
+	if err != nil {
+		return err
+	}
+	return saveTrainer()
+}
+
+// trainerStats is what a trainer has been up to, counted from their
+// encounter log.
+type trainerStats struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Seen     int       `json:"seen"`
+	Caught   int       `json:"caught"`
+	Party    []string  `json:"party"`
+	Throws   int       `json:"throws"`
+	Escaped  int       `json:"escaped"`
+	Explored int       `json:"areas_explored"`
+}
+
+func countStats(events []encounterEvent) trainerStats {
+	var stats trainerStats
+	areas := make(map[string]bool)
+	for _, event := range events {
+		switch event.Event {
+		case "caught":
+			stats.Throws++
+		case "escaped":
+			stats.Throws++
+			stats.Escaped++
+		case "seen":
+			areas[event.Area] = true
+		}
+	}
+	stats.Explored = len(areas)
+	return stats
+}
+
+func trainerCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return showTrainer(ctx)
+	}
+	switch args[0] {
+	case "list":
+		names, err := listTrainers()
+		if err != nil {
+			return err
+		}
+		if OUTPUT_JSON {
+			return printJSON(names)
+		}
+		for _, name := range names {
+			if name == TRAINER {
+				fmt.Printf(" * %s\n", name)
+			} else {
+				fmt.Printf("   %s\n", name)
+			}
+		}
+		return nil
+	case "new", "switch":
+		if len(args) < 2 {
+			fmt.Printf("Usage: trainer %s <name>\n", args[0])
+			return nil
+		}
+		name := args[1]
+		if !trainerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid trainer name %q, use up to 32 letters, digits, - and _", name)
+		}
+		exists := trainerExists(name) || name == TRAINER
+		if args[0] == "new" && exists {
+			return fmt.Errorf("trainer %s already exists, use trainer switch %s", name, name)
+		}
+		if args[0] == "switch" && !exists {
+			return fmt.Errorf("there is no trainer %s, create one with trainer new %s", name, name)
+		}
+		if name == TRAINER {
+			fmt.Printf("You're already playing as %s\n", name)
+			return nil
+		}
+		err := switchTrainer(name)
+		if err != nil {
+			return err
+		}
+		if args[0] == "new" {
+			fmt.Printf("Welcome, %s! Your adventure begins.\n", name)
+		} else {
+			fmt.Printf("Switched to %s\n", name)
+		}
+		return nil
+	}
+	fmt.Println("Usage: trainer [list | new <name> | switch <name>]")
+	return nil
+}
+
+func showTrainer(ctx context.Context) error {
+	events, err := readEncounterLog()
+	if err != nil {
+		return err
+	}
+	stats := countStats(events)
+	caught, seen := playerSpecies()
+	stats.Name, stats.Created, stats.Seen, stats.Caught, stats.Party = TRAINER, TRAINER_CREATED, len(seen), len(caught), PARTY
+	if OUTPUT_JSON {
+		if stats.Party == nil {
+			stats.Party = []string{}
+		}
+		return printJSON(stats)
+	}
+	fmt.Printf("Trainer %s, playing since %s\n", stats.Name, stats.Created.Local().Format("2006-01-02"))
+	fmt.Printf("Pokedex: %d seen, %d caught\n", stats.Seen, stats.Caught)
+	fmt.Printf("Balls thrown: %d, escaped: %d\n", stats.Throws, stats.Escaped)
+	fmt.Printf("Areas explored: %d\n", stats.Explored)
+	if len(PARTY) == 0 {
+		fmt.Println("Party: empty")
+		return nil
+	}
+	fmt.Println("Party:")
+	for _, name := range localNames(ctx, "pokemon-species", PARTY) {
+		fmt.Printf(" - %s\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"pokedexcli/internal"
+	"testing"
+	"time"
+)
+
+func TestTrainerSaveSlots(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	savedTrainer, savedPokemon, savedCaught, savedSeen, savedGame := TRAINER, POKEMON, CAUGHT, SEEN, GAME
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN, GAME = savedTrainer, savedPokemon, savedCaught, savedSeen, savedGame
+		MAP_PAGE = mapPage{size: 20}
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+
+	err := loadTrainer("ash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pikachu pokemonEntry
+	pikachu.ID = 25
+	POKEMON["pikachu"] = pikachu
+	CAUGHT["pikachu"] = caughtRecord{Level: 5}
+	joinParty("pikachu")
+	INVENTORY["poke-ball"] = 5
+	GAME = "red"
+	TRAINER_SETTINGS["game"] = "red"
+	MAP_PAGE = mapPage{region: "kanto", size: 10, offset: 30}
+	recordSightings("viridian-forest-area", []string{"pikachu"})
+
+	err = switchTrainer("misty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(POKEMON) != 0 || len(CAUGHT) != 0 || len(SEEN) != 0 || len(PARTY) != 0 || len(INVENTORY) != 0 || GAME != "" || MAP_PAGE.offset != 0 {
+		t.Errorf("expected misty to start from scratch, got %v, %v, %v, %v, %v, %q, %+v", POKEMON, CAUGHT, SEEN, PARTY, INVENTORY, GAME, MAP_PAGE)
+	}
+	names, err := listTrainers()
+	if err != nil || len(names) != 2 || names[0] != "ash" || names[1] != "misty" {
+		t.Errorf("expected ash and misty, got %v, %v", names, err)
+	}
+
+	err = switchTrainer("ash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if POKEMON["pikachu"].ID != 25 || CAUGHT["pikachu"].Level != 5 || len(PARTY) != 1 || !SEEN["pikachu"]["viridian-forest-area"] || INVENTORY["poke-ball"] != 5 {
+		t.Errorf("expected ash's pikachu and bag back, got %v, %v, %v, %v, %v", POKEMON, CAUGHT, PARTY, SEEN, INVENTORY)
+	}
+	if GAME != "red" || MAP_PAGE.region != "kanto" || MAP_PAGE.offset != 30 {
+		t.Errorf("expected ash's settings and map page back, got %q, %+v", GAME, MAP_PAGE)
+	}
+}
+
+func TestDamagedSave(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	defer func(name string) { TRAINER = name }(TRAINER)
+	DEFAULT_SETTINGS = snapshotSettings()
+
+	TRAINER = "brock"
+	os.MkdirAll(trainerPath(""), 0700)
+	os.WriteFile(trainerPath("save.json"), []byte("{not json"), 0600)
+	if loadTrainer("brock") == nil {
+		t.Fatalf("expected an error loading a damaged save")
+	}
+	if _, err := os.Stat(trainerPath("save.json.damaged")); err != nil {
+		t.Errorf("expected the damaged save to be kept, got %v", err)
+	}
+}
+
+func TestUnreadableSave(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	savedTrainer, savedPokemon, savedCaught, savedSeen := TRAINER, POKEMON, CAUGHT, SEEN
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN = savedTrainer, savedPokemon, savedCaught, savedSeen
+		SAVE_BLOCKED = false
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+
+	loadTrainer("misty")
+	CAUGHT["staryu"] = caughtRecord{Level: 12}
+	saveTrainer()
+	// a directory in place of the save can't be read, like one without
+	// permission
+	os.MkdirAll(dataPath("trainers/ash/save.json"), 0700)
+	if loadTrainer("ash") == nil {
+		t.Fatalf("expected an error loading an unreadable save")
+	}
+	if TRAINER != "misty" || CAUGHT["staryu"].Level != 12 {
+		t.Errorf("expected misty to still be loaded, got %s, %v", TRAINER, CAUGHT)
+	}
+
+	os.WriteFile(dataPath("trainer"), []byte("ash\n"), 0600)
+	loadCurrentTrainer()
+	if TRAINER != "ash" || !SAVE_BLOCKED {
+		t.Fatalf("expected to play as ash without saving, got %s, %v", TRAINER, SAVE_BLOCKED)
+	}
+	if saveTrainer() == nil {
+		t.Errorf("expected saving over the unreadable save to fail")
+	}
+	if info, err := os.Stat(dataPath("trainers/ash/save.json")); err != nil || !info.IsDir() {
+		t.Errorf("expected the unreadable save to be left alone, got %v", err)
+	}
+	err := switchTrainer("misty")
+	if err != nil || SAVE_BLOCKED || CAUGHT["staryu"].Level != 12 {
+		t.Errorf("expected to switch back to misty, got %v, %v, %v", err, SAVE_BLOCKED, CAUGHT)
+	}
+}
+
+func TestTrimPokemon(t *testing.T) {
+	var mon pokemonEntry
+	err := json.Unmarshal([]byte(`{
+		"id": 25,
+		"game_indices": [{"game_index": 84, "version": {"name": "red"}}],
+		"moves": [
+			{"move": {"name": "thunder-shock"}, "version_group_details": [
+				{"level_learned_at": 1, "move_learn_method": {"name": "level-up"}, "version_group": {"name": "red-blue"}},
+				{"level_learned_at": 0, "move_learn_method": {"name": "tutor"}, "version_group": {"name": "emerald"}}
+			]},
+			{"move": {"name": "thunderbolt"}, "version_group_details": [
+				{"level_learned_at": 0, "move_learn_method": {"name": "machine"}, "version_group": {"name": "red-blue"}}
+			]}
+		]
+	}`), &mon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon = trimPokemon(mon)
+	if mon.ID != 25 || mon.GameIndices != nil {
+		t.Errorf("expected only what we use to be kept, got %+v", mon)
+	}
+	if len(mon.Moves) != 1 || mon.Moves[0].Move.Name != "thunder-shock" || len(mon.Moves[0].VersionGroupDetails) != 1 {
+		t.Errorf("expected only thunder-shock learned by levelling up, got %+v", mon.Moves)
+	}
+}
+
+func TestCountStats(t *testing.T) {
+	stats := countStats([]encounterEvent{
+		{Event: "seen", Pokemon: "pikachu", Area: "viridian-forest-area"},
+		{Event: "seen", Pokemon: "caterpie", Area: "viridian-forest-area"},
+		{Event: "seen", Pokemon: "zubat", Area: "mt-moon-1f"},
+		{Event: "escaped", Pokemon: "pikachu"},
+		{Event: "caught", Pokemon: "pikachu"},
+	})
+	if stats.Throws != 2 || stats.Escaped != 1 || stats.Explored != 2 {
+		t.Errorf("expected 2 throws, 1 escape and 2 areas, got %+v", stats)
+	}
+}
+
+func TestCommandsAutosave(t *testing.T) {
+	t.Setenv("POKEDEX_HOME", t.TempDir())
+	API = internal.NewClient(time.Second)
+	savedTrainer, savedPokemon, savedCaught, savedSeen := TRAINER, POKEMON, CAUGHT, SEEN
+	defer func() {
+		TRAINER, POKEMON, CAUGHT, SEEN = savedTrainer, savedPokemon, savedCaught, savedSeen
+		PARTY = nil
+	}()
+	DEFAULT_SETTINGS = snapshotSettings()
+	loadTrainer("ash")
+
+	// commands that change nothing don't save
+	fakeHelp := cliCommand{name: "help", callback: func(ctx context.Context, args []string) error {
+		return nil
+	}}
+	err := runCommand(fakeHelp, nil)
+	if _, statErr := os.Stat(trainerPath("save.json")); err != nil || statErr == nil {
+		t.Errorf("expected no save after help, got %v, %v", err, statErr)
+	}
+
+	fakeCatch := cliCommand{name: "catch", callback: func(ctx context.Context, args []string) error {
+		CAUGHT["pikachu"] = caughtRecord{Level: 5}
+		TRAINER_CHANGED = true
+		return nil
+	}}
+	err = runCommand(fakeCatch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CAUGHT = nil
+	err = loadTrainer("ash")
+	if err != nil || CAUGHT["pikachu"].Level != 5 {
+		t.Errorf("expected the catch to be saved straight away, got %v, %v", CAUGHT, err)
+	}
+}
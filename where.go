@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"pokedexcli/internal"
+	"strconv"
+)
+
+// areaEncounters is one entry of a pokemon's location_area_encounters.
+type areaEncounters struct {
+	LocationArea   namedResource       `json:"location_area"`
+	VersionDetails []versionEncounters `json:"version_details"`
+}
+
+func where(ctx context.Context, args []string) error {
+	words, flags := parseArgs(args, "details")
+	if len(words) < 1 {
+		fmt.Println("Usage: where <pokemon name> [--version <name>] [--explore <n>]")
+		return nil
+	}
+	name := resolveName(words)
+	mon, err := getPokemon(ctx, name)
+	if err != nil {
+		return err
+	}
+	KNOWN_POKEMON[mon.Name] = struct{}{}
+	encounters, err := getEncounters(ctx, mon)
+	if err != nil {
+		return err
+	}
+	version := flags.get("version")
+	if version == "" {
+		version = GAME
+	}
+	var set encounterRowSet
+	for _, enc := range encounters {
+		set.add(encounterRow{Area: enc.LocationArea.Name}, enc.VersionDetails, version, "")
+		KNOWN_AREAS[enc.LocationArea.Name] = struct{}{}
+	}
+	rows := set.list()
+	if OUTPUT_JSON && !flags.has("explore") {
+		return printJSON(rows)
+	}
+
+	if len(rows) == 0 {
+		if version != "" {
+			fmt.Printf("%s can't be found in the wild in %s\n", name, version)
+		} else {
+			fmt.Printf("%s can't be found in the wild\n", name)
+		}
+		return nil
+	}
+	if !OUTPUT_JSON {
+		fmt.Printf("%s can be found in:\n", name)
+		printEncounterTable(rows)
+	}
+	if !flags.has("explore") {
+		return nil
+	}
+	areas := encounterAreas(rows)
+	n, err := strconv.Atoi(flags.get("explore"))
+	if err != nil || n < 1 || n > len(areas) {
+		return fmt.Errorf("--explore takes an area number from 1 to %d", len(areas))
+	}
+	if !OUTPUT_JSON {
+		fmt.Println()
+	}
+	return printPokemon(ctx, areas[n-1], flags)
+}
+
+func getEncounters(ctx context.Context, mon pokemonEntry) ([]areaEncounters, error) {
+	if mon.LocationAreaEncounters == "" {
+		return nil, nil
+	}
+	encounters, lookup, err := WHERE_CACHE.Fetch(ctx, mon.Name, POKEMON_TTL, POKEMON_STALE, func(ctx context.Context) ([]areaEncounters, error) {
+		var encounters []areaEncounters
+		return encounters, API.GetJSON(ctx, mon.LocationAreaEncounters, &encounters)
+	})
+	noteStale(lookup, mon.Name+"'s encounters")
+	if lookup == internal.KnownMissing {
+		return nil, nil
+	}
+	return encounters, err
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestWhereExploreJSON(t *testing.T) {
+	useTestMapAPI(t)
+	API.HTTPClient = &http.Client{Transport: apiTransport{
+		"https://pokeapi.co/api/v2/pokemon/tentacool":                `{"name": "tentacool", "location_area_encounters": "https://pokeapi.co/api/v2/pokemon/72/encounters"}`,
+		"https://pokeapi.co/api/v2/pokemon/72/encounters":            `[{"location_area": {"name": "canalave-city-area"}, "version_details": [{"version": {"name": "diamond"}, "encounter_details": [{"chance": 60, "min_level": 20, "max_level": 30, "method": {"name": "surf"}}]}]}]`,
+		"https://pokeapi.co/api/v2/location-area/canalave-city-area": testArea,
+	}}
+	savedSeen, savedArea, savedGame, savedJSON := SEEN, CURRENT_AREA, GAME, OUTPUT_JSON
+	defer func() { SEEN, CURRENT_AREA, GAME, OUTPUT_JSON = savedSeen, savedArea, savedGame, savedJSON }()
+	SEEN = make(map[string]map[string]bool)
+	GAME = ""
+	OUTPUT_JSON = true
+
+	var err error
+	output := captureStdout(t, func() {
+		err = where(context.Background(), []string{"tentacool", "--explore", "1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var explored exploredArea
+	if err := json.Unmarshal([]byte(output), &explored); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", output, err)
+	}
+	if explored.Area != "canalave-city-area" || len(explored.Pokemon) != 2 {
+		t.Errorf("expected tentacool and hoothoot in canalave-city-area, got %+v", explored)
+	}
+
+	output = captureStdout(t, func() {
+		err = where(context.Background(), []string{"tentacool", "--explore", "1", "--details"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []encounterRow
+	if err := json.Unmarshal([]byte(output), &rows); err != nil || len(rows) != 4 {
+		t.Errorf("expected the area's 4 encounter rows as JSON, got %q: %v", output, err)
+	}
+}